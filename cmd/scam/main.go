@@ -2,15 +2,24 @@ package main
 
 import (
 	"github.mheducation.com/dave-mcmath/scam/repl"
+	"github.mheducation.com/dave-mcmath/scam/sexpr"
 
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"errors"
 )
 
 var infilename = flag.String("in", "-", "input file ('-' for stdin)")
+var allowRedefinition = flag.Bool("allow-redefinition", false, "let define rebind primitives like car")
+var backend = flag.String("backend", "tree", "how to run code: tree, closure or vm")
+var preludeFile = flag.String("prelude", "", "file to use as the prelude, instead of the built-in one")
+var noPrelude = flag.Bool("no-prelude", false, "start without any prelude")
+var libPath = flag.String("lib-path", "", "directories to search for libraries, separated like $PATH")
+var fileRoot = flag.String("file-root", ".", "directory that load, include and the file ports may read and write under ('' for none)")
 
 type teeReader struct{
 	in  io.Reader
@@ -35,6 +44,41 @@ func (t teeReader) Read(p []byte) (n int, err error) {
 func main() {
 	flag.Parse()
 
+	backends := map[string]sexpr.Backend{
+		"tree":    sexpr.BackendTreeWalk,
+		"closure": sexpr.BackendClosure,
+		"vm":      sexpr.BackendVM,
+	}
+	b, ok := backends[*backend]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown backend %q\n", *backend)
+		os.Exit(2)
+	}
+
+	var interp *sexpr.Interpreter
+	switch {
+	case *noPrelude:
+		interp = sexpr.NewBareInterpreter()
+	case *preludeFile != "":
+		src, err := ioutil.ReadFile(*preludeFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read prelude:", err)
+			os.Exit(1)
+		}
+		interp = sexpr.NewBareInterpreter()
+		if err := interp.LoadSource(*preludeFile, string(src)) ; err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load prelude:", err)
+			os.Exit(1)
+		}
+	default:
+		interp = sexpr.NewInterpreter()
+	}
+
+	interp.SetFileRoot(*fileRoot)
+	if *libPath != "" {
+		interp.SetLibraryPath(filepath.SplitList(*libPath))
+	}
+
 	//	var infile *os.Reader
 	var infile io.Reader
 	switch *infilename {
@@ -49,6 +93,8 @@ func main() {
 			fmt.Fprintln(os.Stderr, msg, err)
 			os.Exit(1)
 		}
+		// (load "x.ss") in a file means the x.ss next to it
+		interp.SetDirectory(filepath.Dir(*infilename))
 		infile = teeReader{
 			in: iii,
 			tee: os.Stdout,
@@ -56,10 +102,13 @@ func main() {
 	}
 
 	r := repl.New("scam", infile, os.Stdout, os.Stderr)
+	r.SetInterpreter(interp)
 	r.SetPreface(`SCAM Version 0.1
 Please be gentle
 `)
 	r.SetPrompt("> ")
+	r.SetAllowRedefinition(*allowRedefinition)
+	r.SetBackend(b)
 
 	r.Run()
 }
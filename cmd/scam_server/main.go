@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.mheducation.com/dave-mcmath/scam/repl"
+	"github.mheducation.com/dave-mcmath/scam/sexpr"
 
 	"flag"
 	"log"
@@ -11,6 +12,10 @@ import (
 )
 
 var port = flag.Int("port", 8000, "where to listen")
+var maxSteps = flag.Int64("max-steps", 1000000, "evaluation steps allowed per form (0 for no limit)")
+var maxDepth = flag.Int("max-depth", 10000, "procedure-call depth allowed (0 for no limit)")
+var maxConses = flag.Int64("max-conses", 1000000, "cons cells allowed per form (0 for no limit)")
+
 func main() {
 	flag.Parse()
 
@@ -39,6 +44,11 @@ func handleConn(conn net.Conn) {
 		os.Stderr,
 	)
 	r.SetPrompt(fmt.Sprintf("write(%d)> ", *port))
+	r.SetLimits(sexpr.Limits{
+		MaxSteps:  *maxSteps,
+		MaxDepth:  *maxDepth,
+		MaxConses: *maxConses,
+	})
 	r.Run()
 }
 	
@@ -17,14 +17,31 @@ type repl struct{
 
 	preface string
 	prompt  string
+
+	interp  *sexpr.Interpreter
 }
 
 func New(name string, in io.Reader, out io.Writer, err io.Writer) repl {
-	return repl{name, in, out, err, "", "> "}
+	interp := sexpr.NewInterpreter()
+	// SCAM's error port goes to out as well: err is the host's (the
+	// server's log, for scam_server), not the user's
+	interp.SetOutput(out)
+	interp.SetErrorOutput(out)
+	return repl{name, in, out, err, "", "> ", interp}
 }
 
+// SetInterpreter replaces the interpreter, for one made differently
+// (without the prelude, say).  Use it before the other setters.
+func (r *repl) SetInterpreter(i *sexpr.Interpreter) {
+	i.SetOutput(r.out)
+	i.SetErrorOutput(r.out)
+	r.interp = i
+}
 func (r *repl) SetPreface(p string) { r.preface = p }
 func (r *repl) SetPrompt(p string) { r.prompt = p }
+func (r *repl) SetLimits(l sexpr.Limits) { r.interp.SetLimits(l) }
+func (r *repl) SetAllowRedefinition(b bool) { r.interp.SetAllowRedefinition(b) }
+func (r *repl) SetBackend(b sexpr.Backend) { r.interp.SetBackend(b) }
 
 func (r *repl) Run() {
 	ch := make(chan rune)
@@ -55,7 +72,7 @@ func (r *repl) Run() {
 					back <- ans
 				}
 			}()
-			back <- sexpr.Evaluate(sx)
+			back <- r.interp.Evaluate(sx)
 		}()
 		val := <- back
 		if _, err := sexpr.Fprint(r.out, val) ; err != nil {
@@ -0,0 +1,419 @@
+package sexpr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// The bytecode compiler turns an S-expression into a vmTemplate: a
+// flat sequence of instructions for the stack machine in vm.go.  It
+// resolves variables the same way the closure compiler does (see
+// compiler.go), and falls back to the tree-walking evaluator for the
+// same things, by way of the opMacro instruction.
+//
+// An instruction is 32 bits: an 8-bit opcode and either one 24-bit
+// operand or two 12-bit operands.
+
+type opcode uint8
+const (
+	opConst     opcode = iota // push constants[a]
+	opLocal                   // push slot b of the frame a levels up
+	opGlobal                  // push the global named constants[a]
+	opSetLocal                // pop into slot a of this frame
+	opDefine                  // pop, bind the global constants[a], push a definition
+	opPop                     // throw away the top of the stack
+	opJump                    // go to a
+	opJumpFalse               // pop; go to a if it's falsey
+	opJumpTrue                // pop; go to a if it's truthy
+	opClosure                 // push a procedure made from templates[a]
+	opCall                    // call with a arguments; constants[b] is the unevaluated cdr
+	opTailCall                // like opCall, but replacing this frame
+	opReturn                  // pop, and give it to the caller
+	opBind                    // pop scopes[a]'s values into a new frame
+	opUnbind                  // go back to the enclosing frame
+	opMacro                   // apply the evaluator constants[a] to constants[b]
+)
+
+var opcodeNames = []string{
+	"const", "local", "global", "set-local", "define", "pop",
+	"jump", "jump-false", "jump-true", "closure", "call", "tail-call",
+	"return", "bind", "unbind", "macro",
+}
+
+func (o opcode) String() string { return opcodeNames[o] }
+
+type instr uint32
+
+const (
+	maxOperand  = 1 << 12
+	maxAddress  = 1 << 24
+)
+
+func mkInstr(op opcode, a int) instr { return instr(op) | instr(a) << 8 }
+func mkInstr2(op opcode, a int, b int) instr {
+	return instr(op) | instr(a) << 8 | instr(b) << 20
+}
+func (i instr) op() opcode { return opcode(i & 0xff) }
+func (i instr) a() int     { return int(i >> 8) }
+func (i instr) a12() int   { return int(i >> 8) & (maxOperand - 1) }
+func (i instr) b12() int   { return int(i >> 20) }
+
+// A vmScope describes the frame made by opBind (for "let"): the names
+// in it, and the slots that the values on the stack go into.
+type vmScope struct{
+	names []sexpr_atom
+	positions []int
+}
+
+// A vmTemplate is the compiled form of a lambda (or of a top-level
+// form, which is like a lambda of no arguments run in the root).
+type vmTemplate struct{
+	definition string   // what the procedure prints as
+	nparams int
+	positions []int     // slot for each parameter
+	names []sexpr_atom  // of all the slots
+	code []instr
+	constants []sexpr_general
+	templates []*vmTemplate
+	scopes []vmScope
+}
+
+// Errors from assembling, like running out of operand bits
+var errTooBig = errors.New("Form is too big for the bytecode VM")
+
+type assembler struct{
+	*compiler
+	tmpl *vmTemplate
+	err error
+}
+
+func (a *assembler) emit(op opcode, arg int) int {
+	if arg >= maxAddress {
+		a.err = errTooBig
+	}
+	a.tmpl.code = append(a.tmpl.code, mkInstr(op, arg))
+	return len(a.tmpl.code) - 1
+}
+func (a *assembler) emit2(op opcode, arg1 int, arg2 int) {
+	if arg1 >= maxOperand || arg2 >= maxOperand {
+		a.err = errTooBig
+	}
+	a.tmpl.code = append(a.tmpl.code, mkInstr2(op, arg1, arg2))
+}
+// patch points the jump at pc to the next instruction
+func (a *assembler) patch(pc int) {
+	a.tmpl.code[pc] = mkInstr(a.tmpl.code[pc].op(), len(a.tmpl.code))
+}
+func (a *assembler) constant(s sexpr_general) int {
+	for idx, c := range a.tmpl.constants {
+		// Only atoms are worth sharing (and safe to compare)
+		if atom, ok := c.(sexpr_atom) ; ok && atom == s {
+			return idx
+		}
+	}
+	a.tmpl.constants = append(a.tmpl.constants, s)
+	return len(a.tmpl.constants) - 1
+}
+
+// assembleTopLevel compiles a form to run in the root context
+func (c *compiler) assembleTopLevel(s sexpr_general) (*vmTemplate, error) {
+	a := &assembler{c, &vmTemplate{definition: "top-level"}, nil}
+	if isDefinition(s, c.interp.root) {
+		lst := s.(sexpr_cons).cdr
+		args, err := unconsifyN(lst, 2)
+		var key sexpr_atom
+		ok := false
+		if err == nil {
+			key, ok = args[0].(sexpr_atom)
+		}
+		if ok {
+			a.expression(args[1], nil, false)
+			a.emit(opDefine, a.constant(key))
+		} else {
+			a.macro(macro_expr{"define", evalDefinition}, lst)
+		}
+	} else {
+		a.expression(s, nil, false)
+	}
+	a.emit(opReturn, 0)
+	return a.tmpl, a.err
+}
+
+// macro compiles a call to the tree-walking evaluator
+func (a *assembler) macro(m macro_expr, lst sexpr_general) {
+	a.emit2(opMacro, a.constant(m), a.constant(lst))
+}
+
+func (a *assembler) expression(s sexpr_general, sc *scope, tail bool) {
+	switch s := s.(type) {
+	case sexpr_atom:
+		if s.typ != atomSymbol {
+			a.emit(opConst, a.constant(s))
+		} else if depth, index, local := sc.resolve(s) ; local {
+			a.emit2(opLocal, depth, index)
+		} else {
+			a.emit(opGlobal, a.constant(s))
+		}
+	case sexpr_cons:
+		if m, ok := a.specialForm(s.car, sc) ; ok {
+			a.special(m, s.cdr, sc, tail)
+			return
+		}
+		terms, err := unconsify(s.cdr)
+		if err != nil {
+			// Let the evaluator complain
+			a.macro(macro_expr{"(eval)", evaluateCdr(s.car)}, s.cdr)
+			return
+		}
+		a.expression(s.car, sc, false)
+		for _, term := range terms {
+			a.expression(term, sc, false)
+		}
+		op := opCall
+		if tail {
+			op = opTailCall
+		}
+		a.emit2(op, len(terms), a.constant(s.cdr))
+	case sexpr_vector:
+		a.emit(opConst, a.constant(s))
+	default:
+		panic(fmt.Sprintf("(assemble) Unrecognized Sexpr (type=%T) %v", s, s))
+	}
+}
+
+// evaluateCdr makes an evaluator that puts car back on the front of
+// its argument and evaluates the whole thing
+func evaluateCdr(car sexpr_general) evaluator {
+	return func(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		return evaluateWithContext(mkCons(car, lst), ctx)
+	}
+}
+
+// special is the bytecode version of compileSpecialForm
+func (a *assembler) special(m macro_expr, lst sexpr_general, sc *scope, tail bool) {
+	switch m.definition {
+	case "quote":
+		if args, err := unconsifyN(lst, 1) ; err == nil {
+			a.emit(opConst, a.constant(args[0]))
+			return
+		}
+	case "lambda":
+		if bound, body, definition, err := parseLambda(lst) ; err == nil {
+			a.lambda(bound, body, definition, sc)
+			return
+		}
+	case "let":
+		if a.let(lst, sc, tail) {
+			return
+		}
+	case "and", "or":
+		if a.lazyReduce(m.definition == "and", lst, sc) {
+			return
+		}
+	case "cond":
+		if a.cond(lst, sc, tail) {
+			return
+		}
+	}
+	if form, ok := expandValuesForm(m, lst) ; ok {
+		a.expression(form, sc, tail)
+		return
+	}
+	// else
+	a.macro(m, lst)
+}
+
+func (a *assembler) lambda(bound []sexpr_atom, body []sexpr_general, definition string, sc *scope) {
+	inner := &scope{nil, sc}
+	tmpl := &vmTemplate{definition: definition, nparams: len(bound)}
+	for _, sym := range bound {
+		tmpl.positions = append(tmpl.positions, inner.add(sym))
+	}
+	sub := &assembler{a.compiler, tmpl, nil}
+	sub.body(body, inner, true)
+	sub.emit(opReturn, 0)
+	if sub.err != nil {
+		a.err = sub.err
+	}
+	tmpl.names = inner.names
+	a.tmpl.templates = append(a.tmpl.templates, tmpl)
+	a.emit(opClosure, len(a.tmpl.templates) - 1)
+}
+
+// body is compileBody for bytecode: slots for the definitions, then
+// the expressions, the last of them in tail position (if the body
+// itself is).
+func (a *assembler) body(body []sexpr_general, sc *scope, tail bool) {
+	definitions, body := a.splitBody(body, sc)
+	positions := make([]int, len(definitions))
+	values := make([]sexpr_general, len(definitions))
+	for idx, lst := range definitions {
+		positions[idx] = -1
+		if args, err := unconsifyN(lst, 2) ; err == nil {
+			if key, ok := args[0].(sexpr_atom) ; ok && key.typ == atomSymbol {
+				positions[idx] = sc.add(key)
+				values[idx] = args[1]
+			}
+		}
+	}
+	for idx, lst := range definitions {
+		if positions[idx] < 0 {
+			a.macro(macro_expr{"define", evalDefinition}, lst)
+		} else {
+			a.expression(values[idx], sc, false)
+			a.emit(opSetLocal, positions[idx])
+			continue
+		}
+		a.emit(opPop, 0)
+	}
+	if len(body) == 0 {
+		err := evaluationError{a.tmpl.definition, "Body has definitions but no expression"}
+		a.macro(macro_expr{"body", func(sexpr_general, *evaluationContext) (sexpr_general, sexpr_error) {
+			return nil, err
+		}}, atomConstantNil)
+		return
+	}
+	for idx, expr := range body {
+		last := idx == len(body) - 1
+		a.expression(expr, sc, last && tail)
+		if !last {
+			a.emit(opPop, 0)
+		}
+	}
+}
+
+func (a *assembler) let(lst sexpr_general, sc *scope, tail bool) bool {
+	args, err := unconsifyAtLeastN(lst, 2)
+	if err != nil {
+		return false
+	}
+	bindings, err := unconsify(args[0])
+	if err != nil {
+		return false
+	}
+	inner := &scope{nil, sc}
+	var values []sexpr_general
+	var positions []int
+	for _, b := range bindings {
+		kv, err := unconsifyN(b, 2)
+		if err != nil {
+			return false
+		}
+		key, ok := kv[0].(sexpr_atom)
+		if !ok || key.typ != atomSymbol {
+			return false
+		}
+		values = append(values, kv[1])
+		positions = append(positions, inner.add(key))
+	}
+	for _, v := range values {
+		a.expression(v, sc, false)
+	}
+	// The body will add its definitions to inner, so we fill in the
+	// names afterward
+	a.tmpl.scopes = append(a.tmpl.scopes, vmScope{nil, positions})
+	idx := len(a.tmpl.scopes) - 1
+	a.emit2(opBind, idx, len(values))
+	saved := a.tmpl.definition
+	a.tmpl.definition = "let"
+	a.body(args[1:], inner, tail)
+	a.tmpl.definition = saved
+	a.tmpl.scopes[idx].names = inner.names
+	if !tail {
+		a.emit(opUnbind, 0)
+	}
+	return true
+}
+
+func (a *assembler) lazyReduce(isAnd bool, lst sexpr_general, sc *scope) bool {
+	args, err := unconsify(lst)
+	if err != nil {
+		return false
+	}
+	test, done, finish := opJumpFalse, atomConstantFalse, atomConstantTrue
+	if !isAnd {
+		test, done, finish = opJumpTrue, atomConstantTrue, atomConstantFalse
+	}
+	var jumps []int
+	for _, arg := range args {
+		a.expression(arg, sc, false)
+		jumps = append(jumps, a.emit(test, 0))
+	}
+	a.emit(opConst, a.constant(finish))
+	end := a.emit(opJump, 0)
+	for _, pc := range jumps {
+		a.patch(pc)
+	}
+	a.emit(opConst, a.constant(done))
+	a.patch(end)
+	return true
+}
+
+func (a *assembler) cond(lst sexpr_general, sc *scope, tail bool) bool {
+	clauses, err := unconsify(lst)
+	if err != nil {
+		return false
+	}
+	var tests [][]sexpr_general
+	for _, clause := range clauses {
+		test, err := unconsifyN(clause, 2)
+		if err != nil {
+			return false
+		}
+		tests = append(tests, test)
+	}
+	var ends []int
+	for _, test := range tests {
+		if test[0] == atomConstantElse {
+			a.expression(test[1], sc, tail)
+			ends = append(ends, a.emit(opJump, 0))
+			break
+		}
+		a.expression(test[0], sc, false)
+		next := a.emit(opJumpFalse, 0)
+		a.expression(test[1], sc, tail)
+		ends = append(ends, a.emit(opJump, 0))
+		a.patch(next)
+	}
+	a.emit(opConst, a.constant(atomConstantNil))
+	for _, pc := range ends {
+		a.patch(pc)
+	}
+	return true
+}
+
+// disassemble lists the instructions of a template, one list per
+// instruction: the address, the name of the opcode, and the operands
+// (with constants filled in where that's more helpful).
+func (t *vmTemplate) disassemble() sexpr_general {
+	var lines []sexpr_general
+	for pc, ins := range t.code {
+		line := []sexpr_general{
+			mkAtomNumber(strconv.Itoa(pc)),
+			mkAtomSymbol(ins.op().String()),
+		}
+		switch ins.op() {
+		case opConst, opGlobal, opDefine:
+			line = append(line, mkList(atomConstantQuote, t.constants[ins.a()]))
+		case opClosure:
+			line = append(line, mkAtomSymbol(t.templates[ins.a()].definition))
+		case opMacro:
+			line = append(line,
+				mkAtomSymbol(t.constants[ins.a12()].Sprint()),
+				mkList(atomConstantQuote, t.constants[ins.b12()]),
+			)
+		case opCall, opTailCall:
+			line = append(line, mkAtomNumber(strconv.Itoa(ins.a12())))
+		case opLocal, opBind:
+			line = append(line,
+				mkAtomNumber(strconv.Itoa(ins.a12())),
+				mkAtomNumber(strconv.Itoa(ins.b12())),
+			)
+		case opSetLocal, opJump, opJumpFalse, opJumpTrue:
+			line = append(line, mkAtomNumber(strconv.Itoa(ins.a())))
+		}
+		lines = append(lines, consify(line))
+	}
+	return consify(lines)
+}
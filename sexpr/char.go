@@ -0,0 +1,190 @@
+package sexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Characters are atoms whose name is the (one) character.  They're
+// written #\a, or #\space for the ones with names, or #\x3bb for any
+// of them by code point.
+
+var characterNames = map[string]rune{
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+	"newline":   '\n',
+	"null":      0,
+	"return":    '\r',
+	"space":     ' ',
+	"tab":       '\t',
+}
+
+var characterNamesByRune = func() map[rune]string {
+	ans := make(map[rune]string)
+	for name, r := range characterNames {
+		ans[r] = name
+	}
+	return ans
+}()
+
+// parseCharacter gives the character meant by s, which is what came
+// after the #\
+func parseCharacter(s string) (rune, error) {
+	if utf8.RuneCountInString(s) == 1 {
+		r, _ := utf8.DecodeRuneInString(s)
+		return r, nil
+	}
+	if r, ok := characterNames[s] ; ok {
+		return r, nil
+	}
+	if strings.HasPrefix(s, "x") {
+		if n, err := strconv.ParseUint(s[1:], 16, 32) ; err == nil && utf8.ValidRune(rune(n)) {
+			return rune(n), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown character #\\%s", s)
+}
+
+// writeCharacter is the inverse of parseCharacter (with the #\)
+func writeCharacter(r rune) string {
+	if name, ok := characterNamesByRune[r] ; ok {
+		return `#\` + name
+	}
+	if !unicode.IsGraphic(r) || unicode.IsSpace(r) {
+		return fmt.Sprintf(`#\x%x`, r)
+	}
+	return `#\` + string(r)
+}
+
+// parseCharacters is parseNumbers for characters
+func parseCharacters(args []sexpr_general) ([]rune, error) {
+	chars := make([]rune, len(args))
+	for idx, arg := range args {
+		a, ok := arg.(sexpr_atom)
+		if !ok || a.typ != atomCharacter {
+			return nil, fmt.Errorf("%s is not a character", arg.Sprint())
+		}
+		chars[idx] = a.character()
+	}
+	return chars, nil
+}
+
+// mkCharacterFn is mkNumericFn for characters
+func mkCharacterFn(name string, min, max int, fn func([]rune) (sexpr_general, error)) applicator {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := checkArity(len(args), min, max) ; err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		chars, err := parseCharacters(args)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		ans, err := fn(chars)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		return ans, nil
+	}
+}
+
+func mkCharacterPredicate(name string, test func(rune) bool) applicator {
+	return mkCharacterFn(name, 1, 1, func(chars []rune) (sexpr_general, error) {
+		if test(chars[0]) {
+			return atomConstantTrue, nil
+		}
+		return atomConstantFalse, nil
+	})
+}
+
+func mkCharacterMapper(name string, fn func(rune) rune) applicator {
+	return mkCharacterFn(name, 1, 1, func(chars []rune) (sexpr_general, error) {
+		return mkAtomCharacter(fn(chars[0])), nil
+	})
+}
+
+// mkCharacterComparison makes char=?, char<? and the rest, like
+// mkNumericComparison.  The -ci ones fold case first.
+func mkCharacterComparison(name string, fold bool, test func(rune, rune) bool) applicator {
+	return mkCharacterFn(name, 1, -1, func(chars []rune) (sexpr_general, error) {
+		for idx := 1 ; idx < len(chars) ; idx++ {
+			a, b := chars[idx - 1], chars[idx]
+			if fold {
+				a, b = foldCase(a), foldCase(b)
+			}
+			if !test(a, b) {
+				return atomConstantFalse, nil
+			}
+		}
+		return atomConstantTrue, nil
+	})
+}
+
+// foldCase is char-foldcase: lower case, mostly
+func foldCase(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// digitValue is the value of a decimal digit, in any script.  Unicode
+// puts each script's digits together, in order, starting with zero;
+// so count the digits before this one.
+func digitValue(r rune) (int, bool) {
+	if !unicode.IsDigit(r) {
+		return 0, false
+	}
+	n := 0
+	for unicode.IsDigit(r - rune(n) - 1) {
+		n += 1
+	}
+	return n % 10, true
+}
+
+func fnDigitValue(chars []rune) (sexpr_general, error) {
+	if n, ok := digitValue(chars[0]) ; ok {
+		return mkInt(int64(n)).sexprize(), nil
+	}
+	return atomConstantFalse, nil
+}
+
+func fnIntegerToChar(nums []intOrFloat) (sexpr_general, error) {
+	n, err := nums[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+		return nil, fmt.Errorf("%d is not a Unicode code point", n)
+	}
+	return mkAtomCharacter(rune(n)), nil
+}
+
+var characterFunctions = map[string]applicator {
+	"char?":            mkAtomTypePredicate("char?", atomCharacter),
+	"char->integer":    mkCharacterFn("char->integer", 1, 1, func(chars []rune) (sexpr_general, error) {
+		return mkInt(int64(chars[0])).sexprize(), nil
+	}),
+	"integer->char":    mkNumericFn("integer->char", 1, 1, fnIntegerToChar),
+	"char-upcase":      mkCharacterMapper("char-upcase", unicode.ToUpper),
+	"char-downcase":    mkCharacterMapper("char-downcase", unicode.ToLower),
+	"char-foldcase":    mkCharacterMapper("char-foldcase", foldCase),
+	"char-alphabetic?": mkCharacterPredicate("char-alphabetic?", unicode.IsLetter),
+	"char-numeric?":    mkCharacterPredicate("char-numeric?", unicode.IsDigit),
+	"char-whitespace?": mkCharacterPredicate("char-whitespace?", unicode.IsSpace),
+	"char-upper-case?": mkCharacterPredicate("char-upper-case?", unicode.IsUpper),
+	"char-lower-case?": mkCharacterPredicate("char-lower-case?", unicode.IsLower),
+	"digit-value":      mkCharacterFn("digit-value", 1, 1, fnDigitValue),
+
+	"char=?":     mkCharacterComparison("char=?", false, func(a, b rune) bool { return a == b }),
+	"char<?":     mkCharacterComparison("char<?", false, func(a, b rune) bool { return a < b }),
+	"char>?":     mkCharacterComparison("char>?", false, func(a, b rune) bool { return a > b }),
+	"char<=?":    mkCharacterComparison("char<=?", false, func(a, b rune) bool { return a <= b }),
+	"char>=?":    mkCharacterComparison("char>=?", false, func(a, b rune) bool { return a >= b }),
+	"char-ci=?":  mkCharacterComparison("char-ci=?", true, func(a, b rune) bool { return a == b }),
+	"char-ci<?":  mkCharacterComparison("char-ci<?", true, func(a, b rune) bool { return a < b }),
+	"char-ci>?":  mkCharacterComparison("char-ci>?", true, func(a, b rune) bool { return a > b }),
+	"char-ci<=?": mkCharacterComparison("char-ci<=?", true, func(a, b rune) bool { return a <= b }),
+	"char-ci>=?": mkCharacterComparison("char-ci>=?", true, func(a, b rune) bool { return a >= b }),
+}
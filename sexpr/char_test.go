@@ -0,0 +1,64 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCharacterPrimitives(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ `#\a`, `^#\\a$` },
+		{ `#\space`, `^#\\space$` },
+		{ `#\x41`, `^#\\A$` },
+		{ `#\x3000`, `^#\\x3000$` },
+		{ `'(#\( #\))`, `^\(#\\\( #\\\)\)$` },
+		{ `(char? #\a)`, "^#t$" },
+		{ `(char? "a")`, "^#f$" },
+		{ `(char? 'a)`, "^#f$" },
+		{ `(char->integer #\A)`, "^65$" },
+		{ `(char->integer #\λ)`, "^955$" },
+		{ `(integer->char 955)`, `^#\\λ$` },
+		{ `(integer->char 10)`, `^#\\newline$` },
+		{ `(integer->char -1)`, "-1 is not a Unicode code point" },
+		{ `(integer->char 55296)`, "55296 is not a Unicode code point" },
+		{ `(integer->char 1.5)`, "is not an exact integer" },
+		{ `(char-upcase #\a)`, `^#\\A$` },
+		{ `(char-upcase #\λ)`, `^#\\Λ$` },
+		{ `(char-upcase #\1)`, `^#\\1$` },
+		{ `(char-downcase #\Σ)`, `^#\\σ$` },
+		{ `(char-foldcase #\Σ)`, `^#\\σ$` },
+		{ `(char-alphabetic? #\é)`, "^#t$" },
+		{ `(char-alphabetic? #\1)`, "^#f$" },
+		{ `(char-numeric? #\٣)`, "^#t$" },
+		{ `(char-numeric? #\a)`, "^#f$" },
+		{ `(char-whitespace? #\tab)`, "^#t$" },
+		{ `(char-whitespace? #\x3000)`, "^#t$" },
+		{ `(char-whitespace? #\a)`, "^#f$" },
+		{ `(char-upper-case? #\A)`, "^#t$" },
+		{ `(char-lower-case? #\A)`, "^#f$" },
+		{ `(digit-value #\7)`, "^7$" },
+		{ `(digit-value #\٣)`, "^3$" },
+		{ `(digit-value #\a)`, "^#f$" },
+		{ `(char=? #\a #\a #\a)`, "^#t$" },
+		{ `(char=? #\a #\A)`, "^#f$" },
+		{ `(char<? #\a #\b #\c)`, "^#t$" },
+		{ `(char<? #\a #\c #\b)`, "^#f$" },
+		{ `(char>=? #\b #\b #\a)`, "^#t$" },
+		{ `(char-ci=? #\a #\A)`, "^#t$" },
+		{ `(char-ci<? #\a #\B)`, "^#t$" },
+		{ `(char=? #\a "a")`, `Exception in char=\?: "a" is not a character` },
+		{ `(char-upcase)`, "Expected 1 arguments, got 0" },
+		{ `(eqv? #\a #\a)`, "^#t$" },
+		{ `(equal? '(#\a) (list #\a))`, "^#t$" },
+	}
+
+	for _, test := range tests {
+		got := lastResult(NewInterpreter(), test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// The compiler turns an S-expression into a tree of Go closures,
+// once, so that running it doesn't have to re-walk the conses,
+// re-unconsify argument lists, or hash its way up the symbolTables.
+//
+// Variables bound by "lambda", "let" and internal definitions are
+// resolved at compile time to an address: how many frames up, and
+// which slot in that frame.  Everything else is a global, looked up
+// by name in the root context when it's needed (so forward references
+// from one top-level definition to a later one still work).
+//
+// The compiler knows the core special forms.  Any other macro is
+// compiled into a call to its evaluator, which walks the tree the
+// old-fashioned way; compiled frames are ordinary evaluationContexts,
+// so that works.  The same goes for a special form with bad syntax:
+// we let the evaluator find (and report) the problem when it runs, so
+// errors show up exactly when they would have without compiling.
+
+// compiled code runs in a context, and gives a value or an error
+type compiled func(*evaluationContext) (sexpr_general, sexpr_error)
+
+// A scope is the compile-time picture of a frame: the names in it, in
+// slot order.  The nil scope is the root context.
+type scope struct{
+	names []sexpr_atom
+	parent *scope
+}
+
+// add gives name a slot in s (or finds the one it already has)
+func (s *scope) add(name sexpr_atom) int {
+	for idx, n := range s.names {
+		if n == name {
+			return idx
+		}
+	}
+	s.names = append(s.names, name)
+	return len(s.names) - 1
+}
+
+// resolve finds the address of a: depth frames up, in slot index.
+// ok is false if a isn't lexically bound, so it must be a global.
+func (s *scope) resolve(a sexpr_atom) (depth int, index int, ok bool) {
+	for ptr := s ; ptr != nil ; ptr = ptr.parent {
+		for idx, n := range ptr.names {
+			if n == a {
+				return depth, idx, true
+			}
+		}
+		depth += 1
+	}
+	return 0, 0, false
+}
+
+type compiler struct{
+	interp *Interpreter
+}
+
+// compileTopLevel compiles a form to be run in the root context,
+// which (unlike an expression) may be a definition.
+func (c *compiler) compileTopLevel(s sexpr_general) compiled {
+	if !isDefinition(s, c.interp.root) {
+		return c.compile(s, nil)
+	}
+	// else
+	lst := s.(sexpr_cons).cdr
+	args, err := unconsifyN(lst, 2)
+	if err != nil {
+		return c.fallback(evalDefinition, lst)
+	}
+	key, ok := args[0].(sexpr_atom)
+	if !ok {
+		return c.fallback(evalDefinition, lst)
+	}
+	value := c.compile(args[1], nil)
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		val, err := value(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.bind(key, val) ; err != nil {
+			return nil, evaluationError{"define(binding)", err.Error()}
+		}
+		return sexpr_definition{key}, nil
+	}
+}
+
+func (c *compiler) compile(s sexpr_general, sc *scope) compiled {
+	switch s := s.(type) {
+	case sexpr_atom:
+		if s.typ == atomSymbol {
+			return c.compileReference(s, sc)
+		}
+		// else, it evaluates to itself
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return s, nil
+		}
+	case sexpr_cons:
+		if m, ok := c.specialForm(s.car, sc) ; ok {
+			return c.compileSpecialForm(m, s.cdr, sc)
+		}
+		return c.compileApplication(s, sc)
+	case sexpr_vector:
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return s, nil
+		}
+	default:
+		panic(fmt.Sprintf("(compile) Unrecognized Sexpr (type=%T) %v", s, s))
+	}
+}
+
+// specialForm says whether head names a macro: it's a symbol, not
+// shadowed by a local variable, whose global value is a macro_expr.
+func (c *compiler) specialForm(head sexpr_general, sc *scope) (macro_expr, bool) {
+	sym, ok := head.(sexpr_atom)
+	if !ok || sym.typ != atomSymbol {
+		return macro_expr{}, false
+	}
+	if _, _, local := sc.resolve(sym) ; local {
+		return macro_expr{}, false
+	}
+	val, ok := c.interp.root.get(sym)
+	if !ok {
+		return macro_expr{}, false
+	}
+	m, ok := val.(macro_expr)
+	return m, ok
+}
+
+func (c *compiler) compileReference(a sexpr_atom, sc *scope) compiled {
+	depth, index, local := sc.resolve(a)
+	switch {
+	case !local:
+		// Remember what we found, until the root context changes
+		root := c.interp.root
+		var cached sexpr_general
+		generation := -1
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			if generation == root.interp.generation {
+				return cached, nil
+			}
+			if val, ok := root.get(a) ; ok {
+				cached, generation = val, root.interp.generation
+				return val, nil
+			}
+			return nil, unboundVariableError(a)
+		}
+	case depth == 0:
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			if val := ctx.slots[index] ; val != nil {
+				return val, nil
+			}
+			return nil, unboundVariableError(a)
+		}
+	default:
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			frame := ctx
+			for i := 0 ; i < depth ; i++ {
+				frame = frame.parent
+			}
+			if val := frame.slots[index] ; val != nil {
+				return val, nil
+			}
+			return nil, unboundVariableError(a)
+		}
+	}
+}
+
+func (c *compiler) compileApplication(s sexpr_cons, sc *scope) compiled {
+	operator := c.compile(s.car, sc)
+	terms, uerr := unconsify(s.cdr)
+	if uerr != nil {
+		err := evaluationError{"(eval)", uerr.Error()}
+		return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return nil, err
+		}
+	}
+	operands := make([]compiled, len(terms))
+	for idx, term := range terms {
+		operands[idx] = c.compile(term, sc)
+	}
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := ctx.interp.step() ; err != nil {
+			return nil, err
+		}
+		car, err := operator(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch car := car.(type) {
+		case func_expr:
+			args := make([]sexpr_general, len(operands))
+			for idx, operand := range operands {
+				if args[idx], err = operand(ctx) ; err != nil {
+					return nil, err
+				}
+			}
+			if err := car.checkArguments(args) ; err != nil {
+				return nil, err
+			}
+			return car.apply(args, ctx)
+		case macro_expr:
+			// A macro we couldn't see coming, like ((car (cons and '())) #t)
+			return car.apply(s.cdr, ctx)
+		default:
+			msg := fmt.Sprintf("Attempt to apply non-procedure %q", car)
+			return nil, evaluationError{"(eval)", msg}
+		}
+	}
+}
+
+// fallback compiles a form into a call to the tree-walking evaluator
+func (c *compiler) fallback(eval evaluator, lst sexpr_general) compiled {
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := ctx.interp.step() ; err != nil {
+			return nil, err
+		}
+		return eval(lst, ctx)
+	}
+}
+
+func (c *compiler) compileSpecialForm(m macro_expr, lst sexpr_general, sc *scope) compiled {
+	switch m.definition {
+	case "quote":
+		if args, err := unconsifyN(lst, 1) ; err == nil {
+			return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+				return args[0], nil
+			}
+		}
+	case "lambda":
+		return c.compileLambda(m, lst, sc)
+	case "let":
+		return c.compileLet(m, lst, sc)
+	case "and":
+		return c.compileLazyReduce(m, lst, sc, atomConstantTrue, reduceAnd)
+	case "or":
+		return c.compileLazyReduce(m, lst, sc, atomConstantFalse, reduceOr)
+	case "cond":
+		return c.compileCond(m, lst, sc)
+	}
+	if form, ok := expandValuesForm(m, lst) ; ok {
+		return c.compile(form, sc)
+	}
+	// else, including "define" in an expression context (which is
+	// an error anyway)
+	return c.fallback(m.apply, lst)
+}
+
+func (c *compiler) compileLambda(m macro_expr, lst sexpr_general, sc *scope) compiled {
+	bound, body, definition, err := parseLambda(lst)
+	if err != nil {
+		return c.fallback(m.apply, lst)
+	}
+	inner := &scope{nil, sc}
+	positions := make([]int, len(bound))
+	for idx, sym := range bound {
+		positions[idx] = inner.add(sym)
+	}
+	code := c.compileBody(definition, body, inner)
+	// compileBody may have added slots for internal definitions, so
+	// only now do we know how big a frame is
+	names := inner.names
+	// When the frame is just the arguments, in order, the arguments
+	// can be the frame.  (Callers always hand over a fresh slice.)
+	simple := len(names) == len(bound)
+	for idx, position := range positions {
+		simple = simple && idx == position
+	}
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		apply := func(args []sexpr_general, caller *evaluationContext) (sexpr_general, sexpr_error) {
+			if len(bound) != len(args) {
+				return nil, evaluationError{
+					fmt.Sprintf("%s", definition),
+					fmt.Sprintf("Evaluation with %d arguments, expected %d", len(args), len(bound)),
+				}
+			}
+			if err := ctx.interp.enter() ; err != nil {
+				return nil, err
+			}
+			defer ctx.interp.leave()
+			slots := args
+			if !simple {
+				slots = make([]sexpr_general, len(names))
+				for idx, arg := range args {
+					slots[positions[idx]] = arg
+				}
+			}
+			return code(ctx.extendSlots(names, slots))
+		}
+		return func_expr{definition, apply, false, nil, nil, false, nextSerialNumber()}, nil
+	}
+}
+
+func (c *compiler) compileLet(m macro_expr, lst sexpr_general, sc *scope) compiled {
+	args, err := unconsifyAtLeastN(lst, 2)
+	if err != nil {
+		return c.fallback(m.apply, lst)
+	}
+	bindings, err := unconsify(args[0])
+	if err != nil {
+		return c.fallback(m.apply, lst)
+	}
+	inner := &scope{nil, sc}
+	positions := make([]int, len(bindings))
+	values := make([]compiled, len(bindings))
+	for idx, b := range bindings {
+		kv, err := unconsifyN(b, 2)
+		if err != nil {
+			return c.fallback(m.apply, lst)
+		}
+		key, ok := kv[0].(sexpr_atom)
+		if !ok || key.typ != atomSymbol {
+			return c.fallback(m.apply, lst)
+		}
+		// The values are computed outside the new frame
+		values[idx] = c.compile(kv[1], sc)
+		positions[idx] = inner.add(key)
+	}
+	code := c.compileBody("let", args[1:], inner)
+	names := inner.names
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := ctx.interp.step() ; err != nil {
+			return nil, err
+		}
+		slots := make([]sexpr_general, len(names))
+		for idx, value := range values {
+			val, err := value(ctx)
+			if err != nil {
+				return nil, err
+			}
+			slots[positions[idx]] = val
+		}
+		return code(ctx.extendSlots(names, slots))
+	}
+}
+
+// compileBody is the compiled evalBody.  Internal definitions get
+// slots in sc, all of them before any of their values are compiled, so
+// that they can refer to one another.
+func (c *compiler) compileBody(name string, body []sexpr_general, sc *scope) compiled {
+	definitions, body := c.splitBody(body, sc)
+	steps := make([]compiled, 0, len(definitions) + len(body))
+	type slotted struct{
+		lst sexpr_general
+		args []sexpr_general
+		position int
+	}
+	var pending []slotted
+	for _, lst := range definitions {
+		args, err := unconsifyN(lst, 2)
+		if err != nil {
+			pending = append(pending, slotted{lst, nil, -1})
+			continue
+		}
+		key, ok := args[0].(sexpr_atom)
+		if !ok || key.typ != atomSymbol {
+			pending = append(pending, slotted{lst, nil, -1})
+			continue
+		}
+		pending = append(pending, slotted{lst, args, sc.add(key)})
+	}
+	for _, p := range pending {
+		if p.position < 0 {
+			// Let evalDefinition complain about it
+			lst := p.lst
+			steps = append(steps, func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+				return evalDefinition(lst, ctx)
+			})
+			continue
+		}
+		value := c.compile(p.args[1], sc)
+		position := p.position
+		steps = append(steps, func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			val, err := value(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ctx.slots[position] = val
+			return nil, nil
+		})
+	}
+	for _, expr := range body {
+		steps = append(steps, c.compile(expr, sc))
+	}
+	if len(body) == 0 {
+		// (after the definitions, which might have errors of their own)
+		err := evaluationError{name, "Body has definitions but no expression"}
+		steps = append(steps, func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return nil, err
+		})
+	}
+
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		var ans sexpr_general
+		for _, step := range steps {
+			var err sexpr_error
+			if ans, err = step(ctx) ; err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	}
+}
+
+// splitBody gives the cdrs of the definitions at the start of body,
+// with any define-values rewritten as defines, and the expressions
+// after them
+func (c *compiler) splitBody(body []sexpr_general, sc *scope) ([]sexpr_general, []sexpr_general) {
+	var definitions []sexpr_general
+	for ; len(body) > 0 ; body = body[1:] {
+		if c.isForm(body[0], "define", sc) {
+			definitions = append(definitions, body[0].(sexpr_cons).cdr)
+		} else if c.isForm(body[0], "define-values", sc) {
+			definitions = append(definitions, expandDefineValues(body[0].(sexpr_cons).cdr)...)
+		} else {
+			break
+		}
+	}
+	return definitions, body
+}
+
+// isForm is the compile-time version of the function of the same
+// name: (name ...) where name isn't a local variable.
+func (c *compiler) isForm(s sexpr_general, name string, sc *scope) bool {
+	cons, ok := s.(sexpr_cons)
+	if !ok {
+		return false
+	}
+	m, ok := c.specialForm(cons.car, sc)
+	return ok && m.definition == name
+}
+
+func (c *compiler) compileLazyReduce(
+	m macro_expr,
+	lst sexpr_general,
+	sc *scope,
+	acc sexpr_general,
+	reducer func(acc sexpr_general, val sexpr_general) (sexpr_general, bool),
+) compiled {
+	args, err := unconsify(lst)
+	if err != nil {
+		return c.fallback(m.apply, lst)
+	}
+	terms := make([]compiled, len(args))
+	for idx, arg := range args {
+		terms[idx] = c.compile(arg, sc)
+	}
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := ctx.interp.step() ; err != nil {
+			return nil, err
+		}
+		ans := acc
+		for _, term := range terms {
+			val, err := term(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// else
+			if ans, done := reducer(ans, val) ; done {
+				return ans, nil
+			}
+		}
+		return ans, nil
+	}
+}
+
+func (c *compiler) compileCond(m macro_expr, lst sexpr_general, sc *scope) compiled {
+	clauses, err := unconsify(lst)
+	if err != nil {
+		return c.fallback(m.apply, lst)
+	}
+	tests := make([]compiled, len(clauses)) // nil for "else"
+	exprs := make([]compiled, len(clauses))
+	for idx, clause := range clauses {
+		test, err := unconsifyN(clause, 2)
+		if err != nil {
+			return c.fallback(m.apply, lst)
+		}
+		if test[0] != atomConstantElse {
+			tests[idx] = c.compile(test[0], sc)
+		}
+		exprs[idx] = c.compile(test[1], sc)
+	}
+	return func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := ctx.interp.step() ; err != nil {
+			return nil, err
+		}
+		for idx, test := range tests {
+			if test == nil {
+				return exprs[idx](ctx)
+			}
+			if predicate, err := test(ctx) ; err != nil {
+				return nil, err
+			} else if !isFalsey(predicate) {
+				return exprs[idx](ctx)
+			}
+		}
+		return atomConstantNil, nil
+	}
+}
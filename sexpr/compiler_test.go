@@ -0,0 +1,154 @@
+package sexpr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The examples directory holds the chapters of The Little Schemer
+var exampleFiles, _ = filepath.Glob("../examples/*.ss")
+
+func parseExampleFile(name string, t testing.TB) []sexpr_general {
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forms []sexpr_general
+	_, sexprs := Parse(name, mkRuneChannel(string(content)))
+	for sx := range sexprs {
+		forms = append(forms, sx)
+	}
+	return forms
+}
+
+func runForms(backend Backend, forms []sexpr_general) []string {
+	interp := NewInterpreter()
+	interp.SetBackend(backend)
+	var ans []string
+	for _, sx := range forms {
+		ans = append(ans, interp.Evaluate(sx).Sprint())
+	}
+	return ans
+}
+
+// benchmarkForms times running the forms with the backend.  Each run
+// gets a fresh interpreter, but making one (and loading its prelude)
+// isn't timed: it would swamp the difference between the backends.
+func benchmarkForms(b *testing.B, backend Backend, forms []sexpr_general) {
+	for i := 0 ; i < b.N ; i++ {
+		b.StopTimer()
+		interp := NewInterpreter()
+		interp.SetBackend(backend)
+		b.StartTimer()
+		for _, sx := range forms {
+			interp.Evaluate(sx)
+		}
+	}
+}
+
+// Programs that poke at the corners of each special form, for
+// checking that the compiled backends agree with the tree walker
+var parityPrograms = []string{
+	"(define x 1) (let ([x 2] [y x]) (+ x y))",
+	"(let ([x 1]) (let ([y 2]) (let ([z 3]) (+ x y z))))",
+	"((lambda (x) ((lambda (y) (+ x y)) 2)) 1)",
+	"(define f (lambda (n) (define g (lambda (m) (h m))) (define h (lambda (m) (+ m n))) (g 1))) (f 2)",
+	"(define mk (lambda (n) (lambda (m) (+ n m)))) ((mk 3) 4)",
+	"(let ([car cdr]) (car '(1 2)))",
+	"(and 1 2) (or #f #f) (and) (or) (and #f unbound)",
+	"(cond (#f 1) (else 2)) (cond (#f 1)) (cond (1))",
+	"(lambda (1) x) (lambda (x)) (let ([1 2]) 3) (let ((a)) a) (quote) (quote 1 2)",
+	"(null? (define a 1)) (let () (define q)) (let () (define 1 2) 3)",
+	"(let () (define a 1) (define b (+ a 1)) b) (let () (define a 1))",
+	"((lambda (x y) x) 1) (1 2) (unbound 1) (car . 1)",
+	"((lambda (x x) x) 1 2)",
+	"(define if 1) ((car (cons and '())) #t #f)",
+}
+
+func checkParity(backend Backend, t *testing.T) {
+	for _, program := range parityPrograms {
+		var forms []sexpr_general
+		_, sexprs := Parse("test", mkRuneChannel(program))
+		for sx := range sexprs {
+			forms = append(forms, sx)
+		}
+		want := runForms(BackendTreeWalk, forms)
+		got := runForms(backend, forms)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Backend %d ran %q to\n%s\nwant\n%s", backend, program,
+				strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func checkParityOnExamples(backend Backend, t *testing.T) {
+	if len(exampleFiles) == 0 {
+		t.Skip("No example files")
+	}
+	for _, name := range exampleFiles {
+		forms := parseExampleFile(name, t)
+		want := runForms(BackendTreeWalk, forms)
+		got := runForms(backend, forms)
+		for idx := range want {
+			if got[idx] != want[idx] {
+				t.Errorf("Backend %d ran %s[%d] %s to %s, want %s",
+					backend, name, idx, forms[idx].Sprint(), got[idx], want[idx])
+			}
+		}
+	}
+}
+
+func TestCompilerMatchesTreeWalk(t *testing.T) { checkParity(BackendClosure, t) }
+func TestCompilerMatchesTreeWalkOnExamples(t *testing.T) { checkParityOnExamples(BackendClosure, t) }
+
+// BenchmarkExamples runs each of the example files, start to finish,
+// with each backend.  Parsing happens up front, and isn't timed;
+// neither is making the interpreter.
+//
+//   go test -run XXX -bench Examples ./sexpr
+func BenchmarkExamples(b *testing.B) {
+	backends := []struct{
+		name string
+		backend Backend
+	} {
+		{ "tree", BackendTreeWalk },
+		{ "closure", BackendClosure },
+		{ "vm", BackendVM },
+	}
+	for _, name := range exampleFiles {
+		forms := parseExampleFile(name, b)
+		for _, backend := range backends {
+			b.Run(filepath.Base(name) + "/" + backend.name, func(b *testing.B) {
+				benchmarkForms(b, backend.backend, forms)
+			})
+		}
+	}
+}
+
+// BenchmarkRecursion is less about the primitives, and more about
+// calling lambdas and looking up variables.
+func BenchmarkRecursion(b *testing.B) {
+	program := `
+(define count
+  (lambda (l acc)
+    (cond
+      ((null? l) acc)
+      (else (count (cdr l) (cons (car l) acc))))))
+(define build
+  (lambda (n acc)
+    (cond
+      ((null? n) acc)
+      (else (build (cdr n) (count acc (quote ())))))))
+(build '(a b c d e f g h i j k l m n o p q r s t u v w x y z) '(1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20))
+`
+	var forms []sexpr_general
+	_, sexprs := Parse("bench", mkRuneChannel(program))
+	for sx := range sexprs {
+		forms = append(forms, sx)
+	}
+	b.Run("tree", func(b *testing.B) { benchmarkForms(b, BackendTreeWalk, forms) })
+	b.Run("closure", func(b *testing.B) { benchmarkForms(b, BackendClosure, forms) })
+	b.Run("vm", func(b *testing.B) { benchmarkForms(b, BackendVM, forms) })
+}
@@ -0,0 +1,187 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// Environments are evaluationContexts made into values, for eval.
+// The interaction environment is the root context, where the REPL's
+// definitions go; the report and null environments are fresh top
+// levels with just the primitives (or just the syntax), so that what
+// eval does in them stays there; and the-environment is wherever it's
+// written, local variables and all.
+type sexpr_environment struct{
+	ctx  *evaluationContext
+	name string
+}
+
+func (e sexpr_environment) Sprint() string {
+	return fmt.Sprintf("#<environment %s>", e.name)
+}
+
+func environmentArgument(s sexpr_general) (sexpr_environment, error) {
+	e, ok := s.(sexpr_environment)
+	if !ok {
+		return e, fmt.Errorf("%s is not an environment", s.Sprint())
+	}
+	return e, nil
+}
+
+func symbolArgumentOrError(s sexpr_general) (sexpr_atom, error) {
+	a, ok := symbolArgument(s)
+	if !ok {
+		return a, fmt.Errorf("%s is not a symbol", s.Sprint())
+	}
+	return a, nil
+}
+
+// reachesOut is what the report and null environments leave out:
+// the primitives that get at another environment (eval's is the
+// interaction environment, unless it's told otherwise), the
+// Interpreter's libraries, or files
+var reachesOut = map[string]bool{
+	"eval":                    true,
+	"interaction-environment": true,
+	"the-environment":         true,
+	"define-library":          true,
+	"import":                  true,
+	"load":                    true,
+	"include":                 true,
+	"open-input-file":         true,
+	"open-output-file":        true,
+}
+
+// reportEnvironment makes a new top level with the primitives that
+// keep keeps, and that don't reach out of it
+func (i *Interpreter) reportEnvironment(name string, keep func(sexpr_general) bool) sexpr_environment {
+	ctx := &evaluationContext{make(symbolTable), nil, i, nil, nil}
+	for key, val := range i.libraries["(scheme base)"].env.sym {
+		if !reachesOut[key.name] && keep(val) {
+			ctx.sym[key] = val
+		}
+	}
+	return sexpr_environment{ctx, name}
+}
+
+// versionArgument checks the optional version of the Scheme report
+func versionArgument(args []sexpr_general) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if n, err := parseIntOrFloat(args[0]) ; err == nil {
+		if v, err := n.integer() ; err == nil && (v == 5 || v == 7) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a version of the report (5 or 7)", args[0].Sprint())
+}
+
+func fnSchemeReportEnvironment(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	if err := versionArgument(args) ; err != nil {
+		return nil, err
+	}
+	return ctx.interp.reportEnvironment("scheme-report", func(val sexpr_general) bool {
+		return true
+	}), nil
+}
+
+func fnNullEnvironment(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	if err := versionArgument(args) ; err != nil {
+		return nil, err
+	}
+	return ctx.interp.reportEnvironment("null", func(val sexpr_general) bool {
+		_, ok := val.(macro_expr)
+		return ok
+	}), nil
+}
+
+func fnInteractionEnvironment(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return sexpr_environment{ctx.interp.root, "interaction"}, nil
+}
+
+// evalTheEnvironment is (the-environment), which is where it is
+func evalTheEnvironment(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if lst != atomConstantNil {
+		return nil, evaluationError{"the-environment", "Expected no arguments"}
+	}
+	if ctx == ctx.interp.root {
+		return sexpr_environment{ctx, "interaction"}, nil
+	}
+	return sexpr_environment{ctx, "local"}, nil
+}
+
+// fnEval evaluates its first argument in the environment (by default,
+// the interaction environment).  In the interaction environment that's
+// just like typing it at the REPL.  Elsewhere, it's the tree-walker's
+// job (as in libraries), and definitions are allowed only at a top
+// level: a local environment's variables are already settled.
+func fnEval(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	interp := ctx.interp
+	env := sexpr_environment{interp.root, "interaction"}
+	if len(args) > 1 {
+		var err error
+		if env, err = environmentArgument(args[1]) ; err != nil {
+			return nil, err
+		}
+	}
+	interp.nested += 1
+	defer func() { interp.nested -= 1 }()
+	var val sexpr_general
+	var err sexpr_error
+	switch {
+	case env.ctx == interp.root:
+		val, err = interp.evaluateTopLevel(args[0])
+	case env.ctx.parent == nil && isDefinition(args[0], env.ctx):
+		val, err = evalDefinition(args[0].(sexpr_cons).cdr, env.ctx)
+	default:
+		val, err = evaluateWithContext(args[0], env.ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func fnEnvironmentBound(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	env, err := environmentArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sym, err := symbolArgumentOrError(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := env.ctx.lookup(sym) ; ok {
+		return atomConstantTrue, nil
+	}
+	return atomConstantFalse, nil
+}
+
+func fnEnvironmentRef(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	env, err := environmentArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sym, err := symbolArgumentOrError(args[1])
+	if err != nil {
+		return nil, err
+	}
+	val, ok := env.ctx.lookup(sym)
+	if !ok {
+		return nil, unboundVariableError(sym)
+	}
+	return val, nil
+}
+
+var environmentFunctions = map[string]applicator {
+	"eval":                      mkListFn("eval", 1, 2, fnEval),
+	"environment?":              mkTypePredicate("environment?", func(s sexpr_general) bool {
+		_, ok := s.(sexpr_environment)
+		return ok
+	}),
+	"interaction-environment":   mkListFn("interaction-environment", 0, 0, fnInteractionEnvironment),
+	"scheme-report-environment": mkListFn("scheme-report-environment", 0, 1, fnSchemeReportEnvironment),
+	"null-environment":          mkListFn("null-environment", 0, 1, fnNullEnvironment),
+	"environment-bound?":        mkListFn("environment-bound?", 2, 2, fnEnvironmentBound),
+	"environment-ref":           mkListFn("environment-ref", 2, 2, fnEnvironmentRef),
+}
@@ -0,0 +1,67 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(eval '(+ 1 2))", "^3$" },
+		{ "(eval '(* 2 3) (interaction-environment))", "^6$" },
+		{ "(eval (list 'car (list 'quote '(a b))) (scheme-report-environment 5))", "^a$" },
+		// Definitions in the interaction environment are the REPL's
+		{ "(eval '(define x 5) (interaction-environment)) x", "^5$" },
+		{ "(eval '(define sq (lambda (n) (* n n)))) (sq 4)", "^16$" },
+		// but in a report environment, they stay there
+		{ `(define env (scheme-report-environment 7))
+		   (eval '(define x 5) env)
+		   (list (eval 'x env) (environment-bound? (interaction-environment) 'x))`, `^\(5 #f\)$` },
+		{ `(define env (scheme-report-environment))
+		   (eval '(define car cdr) env)
+		   (list (eval '(car '(1 2)) env) (car '(1 2)))`, `^\(\(2\) 1\)$` },
+		{ "(define secret 1) (eval 'secret (scheme-report-environment 5))", "secret\\) is not bound" },
+		{ "(add1 1) (eval '(add1 1) (scheme-report-environment 5))", "add1\\) is not bound" },
+		{ "(eval '(quote x) (null-environment 5))", "^x$" },
+		{ "(eval '(car '(1)) (null-environment 5))", "car\\) is not bound" },
+		{ "(scheme-report-environment 6)", "6 is not a version of the report" },
+		// and nothing in them reaches back out
+		{ "(eval '(eval '(define leaked 1) (interaction-environment)) (scheme-report-environment))", "eval\\) is not bound" },
+		{ "(eval '(eval '(define leaked 1) (interaction-environment)) (scheme-report-environment)) leaked", "leaked\\) is not bound" },
+		{ "(eval '(the-environment) (null-environment))", "the-environment\\) is not bound" },
+		{ `(eval '(load "file.ss") (scheme-report-environment))`, "load\\) is not bound" },
+		{ `(eval '(open-output-file "file.ss") (scheme-report-environment))`, "open-output-file\\) is not bound" },
+		{ "(eval '(define-library (leaked)) (scheme-report-environment))", "define-library\\) is not bound" },
+		// The environment where it's written, local variables and all
+		{ `(define f (lambda (a) (let ((b 2)) (the-environment))))
+		   (define env (f 1))
+		   (eval '(+ a b) env)`, "^3$" },
+		{ `(define counter (lambda () (let ((n 0)) (the-environment))))
+		   (eval 'n (counter))`, "^0$" },
+		{ "(eq? (the-environment) (interaction-environment))", "^#t$" },
+		{ "(define x 7) (eval 'x (the-environment))", "^7$" },
+		{ "(define f (lambda () (the-environment))) (eval '(define y 1) (f))", "Invalid context for definition" },
+		{ "(the-environment 1)", "Expected no arguments" },
+		{ "(environment-ref (interaction-environment) 'car)", "car" },
+		{ "(define f (lambda (a) (the-environment))) (environment-ref (f 9) 'a)", "^9$" },
+		{ "(environment-ref (interaction-environment) 'nope)", "nope\\) is not bound" },
+		{ "(environment-bound? (interaction-environment) 'cons)", "^#t$" },
+		{ "(environment-bound? (null-environment) 'cons)", "^#f$" },
+		{ "(environment-bound? (interaction-environment) \"cons\")", "is not a symbol" },
+		{ "(eval 1 2)", "2 is not an environment" },
+		{ "(environment? (interaction-environment))", "^#t$" },
+		{ "(interaction-environment)", "^#<environment interaction>$" },
+		// A continuation can escape from inside eval
+		{ "(+ 1 (call/cc (lambda (k) (eval '(k 41) (the-environment)))))", "^42$" },
+		// Errors in eval are just errors
+		{ "(eval '(car '()))", "car" },
+		// The evaluated program is still under the limits
+		{ "(define loop (lambda () (loop))) (eval '(loop))", "^Exception in eval: Exceeded the limit of 100000 evaluation steps$" },
+	}
+
+	checkBackends(t, tests, func(interp *Interpreter) {
+		interp.SetLimits(Limits{MaxSteps: 100000})
+	})
+}
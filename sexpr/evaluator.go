@@ -5,49 +5,25 @@ import (
 	// "log"
 )
 
-var globalEvaluationContext evaluationContext // define always writes here
+// defaultInterpreter backs the package-level Evaluate
+var defaultInterpreter *Interpreter
 
 func init() {
 	resetEvaluationContext()
 }
-// reset the rootSymbolTable.  This is useful for testing!
+// reset the default Interpreter.  This is useful for testing!
 func resetEvaluationContext() {
-	globalEvaluationContext = evaluationContext{
-		make(map[sexpr_atom]sexpr_general),
-		nil,
-	}
-
-	// Pre-make all the primitive symbols.  Maybe these need to be their
-	// own things; we'll see how Evaluate goes
-	for str, eva := range primitiveMacros {
-		globalEvaluationContext.bind(
-			mkAtomSymbol(str),
-			macro_expr{str, eva},
-		)
-	}
-	for str, eva := range primitiveFunctions {
-		globalEvaluationContext.bind(
-			mkAtomSymbol(str),
-			func_expr{str, eva},
-		)
-	}
+	defaultInterpreter = NewInterpreter()
 }
 
 func Evaluate(s sexpr_general) sexpr_general {
-	if val, err := evaluateWithContext(s, &globalEvaluationContext) ; err != nil {
-		// The error is an S-expression, because it can Sprint!
-		return err
-		// // We should really do something more dramatic ("panic"?) but
-		// // that won't help debugging, so we're permissive (and abuse
-		// // "atom")
-		// log.Print(err)
-		// return mkAtomSymbol(err.Error())
-	} else {
-		return val
-	}
+	return defaultInterpreter.Evaluate(s)
 }
 
 func evaluateWithContext(s sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if err := ctx.interp.step() ; err != nil {
+		return nil, err
+	}
 	switch s := s.(type) {
 	case sexpr_atom: return s.evaluate(ctx)
 	case sexpr_cons:
@@ -70,7 +46,10 @@ func evaluateWithContext(s sexpr_general, ctx *evaluationContext) (sexpr_general
 					return nil, err
 				}
 			}
-			return car.apply(args)
+			if err := car.checkArguments(args) ; err != nil {
+				return nil, err
+			}
+			return car.apply(args, ctx)
 		case macro_expr:
 			// Macros might do anything; give it the context
 			return car.apply(s.cdr, ctx)
@@ -78,12 +57,31 @@ func evaluateWithContext(s sexpr_general, ctx *evaluationContext) (sexpr_general
 			msg := fmt.Sprintf("Attempt to apply non-procedure %q", car)
 			return nil, evaluationError{"(eval)", msg}
 		}
+	case sexpr_vector:
+		// Vector literals evaluate to themselves
+		return s, nil
 	default:
 		panic(fmt.Sprintf("(Evaluate) Unrecognized Sexpr (type=%T) %v", s, s))
 	}
 	return atomConstantNil, nil
 }
 
+// A sexpr_definition is what a top-level (define ...) gives back.
+// It isn't a value (nothing can hold on to it); it's just a note for
+// the REPL to print.
+type sexpr_definition struct{
+	name sexpr_atom
+}
+func (d sexpr_definition) Sprint() string {
+	return fmt.Sprintf(";; defined %s", d.name.Sprint())
+}
+
+// sexpr_unspecified is the value of things done only for their
+// effect, like for-each.  It prints as nothing at all.
+type sexpr_unspecified struct{}
+func (u sexpr_unspecified) Sprint() string { return "" }
+var unspecified = sexpr_unspecified{}
+
 // An evaluator is a decorated S-expression (probably an Atom) that
 // can, when it appears in the Car of a Cons, evaluate the expression
 // into a new S-expression
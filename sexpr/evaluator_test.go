@@ -54,7 +54,7 @@ func ExampleArithmetic() {
 	}
 	// Output:
 	// Evaluating (- 3 2 1)
-	// gave Exception in -: Expected 2 arguments, got 3
+	// gave 0
 }
 
 func TestEvaluateEqQ(t *testing.T) {
@@ -71,11 +71,11 @@ func TestEvaluateEqQ(t *testing.T) {
 		{ "(cons '1 ())", []sexpr_general{ mkList(atomone) } },
 		{
 			"(define a 2)(= 2 a)",
-			[]sexpr_general{ atomConstantNil, atomConstantTrue },
+			[]sexpr_general{ mkDefinition("a"), atomConstantTrue },
 		},
 		{
 			"(define a 1)(define b 2)(cons a b)",
-			[]sexpr_general{ atomConstantNil, atomConstantNil, mkCons(atomone, atomtwo) },
+			[]sexpr_general{ mkDefinition("a"), mkDefinition("b"), mkCons(atomone, atomtwo) },
 		},
 		{ "(eq? '() '())", []sexpr_general{ atomConstantTrue } },
 	}
@@ -86,6 +86,98 @@ func TestEvaluateEqQ(t *testing.T) {
 	}
 }
 
+// TestEquivalencePredicates follows the examples in R7RS section 6.1,
+// leaving out the ones it calls unspecified
+func TestEquivalencePredicates(t *testing.T) {
+	tests := []struct{
+		input string
+		eq, eqv, equal bool
+	} {
+		{ "'a 'a", true, true, true },
+		{ "'a 'b", false, false, false },
+		{ "'() '()", true, true, true },
+		{ "#t #t", true, true, true },
+		{ "#t #f", false, false, false },
+		{ "1 1", true, true, true },
+		{ "100000000 100000000", true, true, true },
+		{ "2 2.0", false, false, false },
+		{ "2.0 2.00", false, true, true },
+		{ "(cons 1 2) (cons 1 2)", false, false, true },
+		{ "'(a (b) c) '(a (b) c)", false, false, true },
+		{ "'(a (b) c) '(a (b) d)", false, false, false },
+		{ "'(1 2) '(1 2 3)", false, false, false },
+		{ "x x", true, true, true },
+		{ "(lambda () 1) (lambda () 2)", false, false, false },
+		{ "p p", true, true, true },
+		{ "car car", true, true, true },
+		{ "car cdr", false, false, false },
+		{ "cond cond", true, true, true },
+		{ "\"abc\" \"abc\"", true, true, true },
+		{ "\"abc\" \"abd\"", false, false, false },
+		{ "'a \"a\"", false, false, false },
+	}
+
+	setup := "(define x '(a)) (define p (lambda (x) x))"
+	for _, test := range tests {
+		for _, pred := range []struct{
+			name string
+			want bool
+		} {
+			{ "eq?", test.eq }, { "eqv?", test.eqv }, { "equal?", test.equal },
+		} {
+			input := fmt.Sprintf("%s (%s %s)", setup, pred.name, test.input)
+			got := lastResult(NewInterpreter(), input)
+			want := atomConstantFalse
+			if pred.want {
+				want = atomConstantTrue
+			}
+			if got != sexpr_general(want) {
+				t.Errorf("(%s %s) = %s, want %s", pred.name, test.input, got.Sprint(), want.Sprint())
+			}
+		}
+	}
+}
+
+func TestTypePredicates(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ "(atom? 'a)", "^#t$" },
+		{ "(atom? 1)", "^#t$" },
+		{ "(atom? '())", "^#f$" },
+		{ "(atom? '(a))", "^#f$" },
+		{ "(symbol? 'a)", "^#t$" },
+		{ "(symbol? \"a\")", "^#f$" },
+		{ "(symbol? '())", "^#f$" },
+		{ "(boolean? #f)", "^#t$" },
+		{ "(boolean? '())", "^#f$" },
+		{ "(string? \"a\")", "^#t$" },
+		{ "(procedure? car)", "^#t$" },
+		{ "(procedure? (lambda (x) x))", "^#t$" },
+		{ "(procedure? 'car)", "^#f$" },
+		{ "(procedure? cond)", "^#f$" },
+		{ "(list? '(a b))", "^#t$" },
+		{ "(list? '())", "^#t$" },
+		{ "(list? (cons 1 2))", "^#f$" },
+		{ "(list? (cons 1 (cons 2 3)))", "^#f$" },
+		{ "(boolean=? #t #t)", "^#t$" },
+		{ "(boolean=? #f #f #t)", "^#f$" },
+		{ "(boolean=? #t)", "Exception in boolean=\\?: Expected at least 2 arguments, got 1" },
+		{ "(boolean=? #t 1)", "Exception in boolean=\\?: 1 is not a boolean" },
+		{ "(pair? 1 2)", "Exception in pair\\?: Expected 1 arguments, got 2" },
+		{ "(number? 1 2)", "Exception in number\\?: Expected 1 arguments, got 2" },
+		{ "(zero? 1 2)", "Exception in zero\\?: Expected 1 arguments, got 2" },
+	}
+
+	for _, test := range tests {
+		got := lastResult(NewInterpreter(), test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
+
 func TestEvaluateLogic(t *testing.T) {
 	var tests = []struct{
 		input string
@@ -149,15 +241,15 @@ func TestEvaluateQuote(t *testing.T) {
 	} {
 		{
 			"(define a 2)'(a a)",
-			[]sexpr_general{ atomConstantNil, mkList(atoma, atoma) },
+			[]sexpr_general{ mkDefinition("a"), mkList(atoma, atoma) },
 		},
 		{
 			"(define a 3)(define b 2)(cons 'a (cons 'b '()))",
-			[]sexpr_general{ atomConstantNil, atomConstantNil, mkList(atoma, atomb) },
+			[]sexpr_general{ mkDefinition("a"), mkDefinition("b"), mkList(atoma, atomb) },
 		},
 		{
 			"(define a 1) 'a",
-			[]sexpr_general{ atomConstantNil, atoma },
+			[]sexpr_general{ mkDefinition("a"), atoma },
 		},
 	}
 
@@ -178,7 +270,7 @@ func TestEvaluatorBinding(t *testing.T) {
 (define b 2)
 (let ([a 3] [b 4]) (= 7 (+ a b)))
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantNil, atomConstantTrue },
+			[]sexpr_general{ mkDefinition("a"), mkDefinition("b"), atomConstantTrue },
 		},
 		{ // Shadowing
 			`
@@ -186,7 +278,7 @@ func TestEvaluatorBinding(t *testing.T) {
 (define b 2)
 (let ([b 5]) (+ a b))
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantNil, mkAtomNumber("6") },
+			[]sexpr_general{ mkDefinition("a"), mkDefinition("b"), mkAtomNumber("6") },
 		},
 		{
 			"(let ([a 3] [b 4]) (= 7 (+ a b)))",
@@ -199,7 +291,7 @@ func TestEvaluatorBinding(t *testing.T) {
 (let ([b 5]) (+ a b))
 (+ a b)
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantNil, mkAtomNumber("6"), atomthree },
+			[]sexpr_general{ mkDefinition("a"), mkDefinition("b"), mkAtomNumber("6"), atomthree },
 		},
 	}
 
@@ -218,8 +310,8 @@ func TestEvaluatorBinding2(t *testing.T) {
 	_, sexprs := Parse("test", mkRuneChannel(program1))
 	s1_1 := <- sexprs
 	s1_1 = Evaluate(s1_1)
-	if s1_1 != atomConstantNil {
-		t.Errorf("Binding: define got %v, want atomConstantNil", s1_1)
+	if s1_1 != mkDefinition("alpha") {
+		t.Errorf("Binding: define got %v, want ;; defined alpha", s1_1)
 	}
 	s1_2 := <- sexprs
 	s1_2 = Evaluate(s1_2)
@@ -268,8 +360,8 @@ func TestEvaluatorBinding2(t *testing.T) {
 	}
 	s3_2 := <- sexprs
 	s3_2 = Evaluate(s3_2)
-	if s3_2 != atomConstantNil {
-		t.Errorf("Binding: define got %v, want atomConstantNil", s3_2)
+	if s3_2 != mkDefinition("alpha") {
+		t.Errorf("Binding: define got %v, want ;; defined alpha", s3_2)
 	}
 	s3_3 := <- sexprs
 	s3_3 = Evaluate(s3_3)
@@ -294,25 +386,25 @@ func TestEvaluatorLambda(t *testing.T) {
 (define add1 (lambda (x) (+ x 1)))
 (= (add1 1) 2)
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantTrue },
+			[]sexpr_general{ mkDefinition("add1"), atomConstantTrue },
 		},
 		{
 			`
 (define add1 (lambda (x) (+ x 1)))
 (add1 2)
 `,
-			[]sexpr_general{ atomConstantNil, atomthree },
+			[]sexpr_general{ mkDefinition("add1"), atomthree },
 		},
 		{
 			`
-(define atom?
+(define my-atom?
  (lambda (x)
     (and (not (pair? x)) (not (null? x)))))
-(atom? 'a)
-(atom? '())
-(atom? '(a b))
+(my-atom? 'a)
+(my-atom? '())
+(my-atom? '(a b))
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantTrue, atomConstantFalse, atomConstantFalse },
+			[]sexpr_general{ mkDefinition("my-atom?"), atomConstantTrue, atomConstantFalse, atomConstantFalse },
 		},
 		{
 			`
@@ -321,7 +413,7 @@ func TestEvaluatorLambda(t *testing.T) {
 (nonpair? '())
 (nonpair? '(a b))
 `,
-			[]sexpr_general{ atomConstantNil, atomConstantTrue, atomConstantTrue, atomConstantFalse },
+			[]sexpr_general{ mkDefinition("nonpair?"), atomConstantTrue, atomConstantTrue, atomConstantFalse },
 		},
 		{ // Chapter 10
 			`
@@ -344,6 +436,37 @@ func TestEvaluatorLambda(t *testing.T) {
 	}
 }
 
+func TestEvaluatorDefinitionContexts(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(define a 1)", "^;; defined a$" },
+		{ "(null? (define a 1))", "Exception in define\\(syntax\\): Invalid context for definition \\(define a 1\\)" },
+		{ "(cond (#t (define a 1)))", "Invalid context for definition" },
+		{ "(define f (lambda (x) (define y 2) (+ x y))) (f 1)", "^3$" },
+		{ "(define f (lambda (x) (define y 2) (+ x y))) (f 1) y", "Variable Sym\\(y\\) is not bound" },
+		{ "(let ([x 1]) (define y 2) (define z 3) (+ x y z))", "^6$" },
+		{ "(let ([x 1]) x (define y 2) y)", "Invalid context for definition \\(define y 2\\)" },
+		{ "(let ([x 1]) (define y 2))", "Body has definitions but no expression" },
+		{ "(let ([define 1]) define)", "^1$" },
+	}
+
+	for _, test := range tests {
+		resetEvaluationContext()
+		_, sexprs := Parse("test", mkRuneChannel(test.input))
+		var got sexpr_general
+		for sx := range sexprs {
+			got = Evaluate(sx)
+		}
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q",
+				test.input, got.Sprint(), test.want,
+			)
+		}
+	}
+}
+
 func ExampleEvaluatorBinding() {
 	resetEvaluationContext()
 	program := `
@@ -362,7 +485,7 @@ func ExampleEvaluatorBinding() {
 	// > (= a 1)
 	// Exception in lookup: Variable Sym(a) is not bound
 	// > (define a 1)
-	// ()
+	// ;; defined a
 	// > (= a 1)
 	// #t
 }
@@ -402,6 +525,10 @@ func TestLambdaError(t *testing.T) {
 /////
 // Helpers
 /////
+func mkDefinition(name string) sexpr_general {
+	return sexpr_definition{mkAtomSymbol(name)}
+}
+
 func helpConfirmEvaluation(input string, want []sexpr_general, t *testing.T) {
 		_, sexprs := Parse("test", mkRuneChannel(input))
 		idx := 0
@@ -1,24 +1,35 @@
 package sexpr
 
 import (
-	"strconv"
 	"fmt"
-	"errors"
-	"math"
 )
 
 // Functions are first class objects.  The file defines operations
 // with functions and some with just macros.
 
-type applicator func([]sexpr_general) (sexpr_general, sexpr_error)
+// An applicator gets the caller's context too, so that primitives can
+// allocate against (and otherwise consult) the running Interpreter.
+type applicator func([]sexpr_general, *evaluationContext) (sexpr_general, sexpr_error)
 type func_expr struct{
 	definition string
 	// A function is handed its arguments pre-evaluated
 	apply applicator
+	// Built in (from primitiveFunctions), rather than made by lambda
+	primitive bool
+	// Made by the bytecode VM (see vm.go); nil otherwise
+	vm *vmProcedure
+	// Made by make-parameter (see parameter.go); nil otherwise
+	param *parameter
+	// Can be handed multiple values as an argument (see values.go);
+	// nothing else can
+	takesValues bool
+	// Keeps separate procedures different, for eq?
+	serialNumber int64
 }
 func (f func_expr) Sprint() string {
 	return fmt.Sprintf("fn:%s", f.definition)
 }
+func (f func_expr) String() string { return f.Sprint() }
 
 type macro_expr struct{
 	definition string
@@ -27,6 +38,7 @@ type macro_expr struct{
 func (m macro_expr) Sprint() string {
 	return fmt.Sprintf("ma:%s", m.definition)
 }
+func (m macro_expr) String() string { return m.Sprint() }
 
 // Certain primitive Atom(symbol)s are built-in.  They can't be
 // implemented given other things defineable in the SCAM language, so
@@ -43,43 +55,63 @@ var primitiveMacros = map[string]evaluator {
 	"define": evalDefine,
 	"let":    evalLet,
 	"lambda": evalLambda,
-	"and":    mkLazyReduce("and", atomConstantTrue, func(acc sexpr_general, val sexpr_general) (sexpr_general, bool) {
-		if isFalsey(val) {
-			return atomConstantFalse, true
-		} else {
-			return acc, false
-		}
-	}),
-	"or":     mkLazyReduce("or", atomConstantFalse, func(acc sexpr_general, val sexpr_general) (sexpr_general, bool) {
-		if !isFalsey(val) {
-			return atomConstantTrue, true
-		} else {
-			return acc, false
-		}
-	}),
+	"and":    mkLazyReduce("and", atomConstantTrue, reduceAnd),
+	"or":     mkLazyReduce("or", atomConstantFalse, reduceOr),
 	"if":     mkTodoEvaluator("if"),
 	"cond":   evalCond,
+	"include": evalInclude,
+	"import":  evalImport,
+	"define-library": evalDefineLibrary,
+	"define-record-type": evalDefineRecordType,
+	"the-environment": evalTheEnvironment,
+	"delay":       mkDelay("delay", true),
+	"delay-force": mkDelay("delay-force", false),
+	"cons-stream": evalConsStream,
+	"let-values":    mkLetValues("let-values", false),
+	"let*-values":   mkLetValues("let*-values", true),
+	"receive":       evalReceive,
+	"define-values": evalDefineValues,
+	"parameterize":  evalParameterize,
 }
 
 func mkTodoApplicator(s string) applicator {
-	return func(ignore []sexpr_general) (sexpr_general, sexpr_error) {
+	return func(ignore []sexpr_general, i2 *evaluationContext) (sexpr_general, sexpr_error) {
 		return nil, evaluationError{s, "is not yet implemented"}
 	}
 }
 
+// The primitive functions come in several tables, by subject (see
+// number.go, for instance).  NewInterpreter binds all of them.
+var primitiveFunctionTables = []map[string]applicator {
+	primitiveFunctions,
+	numericFunctions,
+	listFunctions,
+	characterFunctions,
+	vectorFunctions,
+	hashTableFunctions,
+	outputFunctions,
+	portFunctions,
+	readFunctions,
+	environmentFunctions,
+	promiseFunctions,
+	valuesFunctions,
+	parameterFunctions,
+	symbolFunctions,
+}
+
 var primitiveFunctions = map[string]applicator {
-	"+":      mkArithmeticReduce("+", zeroIntOrFloat, reducePlus),
-	"*":      mkArithmeticReduce("*", oneIntOrFloat, reduceTimes),
-	"expt":   mkNaryFn("expt", 2, fnExponent),
-	"-":      mkNaryFn("-", 2, fnMinus),
-	"/":      mkNaryFn("/", 2, fnDivide),
-	"cons":   mkNaryFn("cons", 2, func(args []sexpr_general) (sexpr_general, sexpr_error) {
-		return mkCons(args[0], args[1]), nil
-	}),
+	"cons":   func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if len(args) != 2 {
+			msg := fmt.Sprintf("Expected 2 arguments, got %d", len(args))
+			return nil, evaluationError{"cons", msg}
+		}
+		return ctx.mkCons(args[0], args[1])
+	},
 	"car":    mkConsSelector("car", func (c sexpr_cons) sexpr_general { return c.car }),
 	"cdr":    mkConsSelector("cdr", func (c sexpr_cons) sexpr_general { return c.cdr }),
-	"=":      mkNaryFn("=", 2, fnEqualNumber),
-	"eq?":    mkNaryFn("eq?", 2, fnEqualAtom),
+	"eq?":    mkNaryFn("eq?", 2, mkEquivalence(isEq)),
+	"eqv?":   mkNaryFn("eqv?", 2, mkEquivalence(isEqv)),
+	"equal?": mkNaryFn("equal?", 2, mkEquivalence(isEqual)),
 	"null?":  mkNaryFn("null?", 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
 		if args[0] == atomConstantNil {
 			return atomConstantTrue, nil
@@ -102,19 +134,34 @@ var primitiveFunctions = map[string]applicator {
 			return atomConstantTrue, nil
 		}
 	}),
-	"zero?":  mkNaryFn("pair?", 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
-		if args[0] == atomConstantZero {
-			return atomConstantTrue, nil
-		} else {
-			return atomConstantFalse, nil
-		}
+	"atom?":      mkTypePredicate("atom?", func(s sexpr_general) bool {
+		// The Little Schemer's atom?: anything but a pair or ()
+		_, pair := s.(sexpr_cons)
+		return !pair && s != sexpr_general(atomConstantNil)
 	}),
-	"number?":  mkNaryFn("pair?", 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
-		switch a := args[0].(type) {
-		case sexpr_atom:
-			if a.typ == atomNumber {
+	"symbol?":    mkAtomTypePredicate("symbol?", atomSymbol),
+	"boolean?":   mkAtomTypePredicate("boolean?", atomBoolean),
+	"string?":    mkAtomTypePredicate("string?", atomString),
+	"procedure?": mkTypePredicate("procedure?", func(s sexpr_general) bool {
+		_, ok := s.(func_expr)
+		return ok
+	}),
+	"list?":      mkTypePredicate("list?", isList),
+	"boolean=?":  mkAtomEquality("boolean=?", atomBoolean, "a boolean"),
+	"string=?":   mkAtomEquality("string=?", atomString, "a string"),
+	"call/cc": fnCallCC,
+	"call-with-current-continuation": fnCallCC,
+	"disassemble": mkNaryFn("disassemble", 1, fnDisassemble),
+	"load": fnLoad,
+	"primitive?": mkNaryFn("primitive?", 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		switch f := args[0].(type) {
+		case func_expr:
+			if f.primitive {
 				return atomConstantTrue, nil
 			}
+		case macro_expr:
+			// There's no way (yet) to make your own macro
+			return atomConstantTrue, nil
 		}
 		// else
 		return atomConstantFalse, nil
@@ -127,7 +174,7 @@ var primitiveFunctions = map[string]applicator {
 // mkNaryFn makes an n-ary "normal" function, one that operates on
 // its arguments after they've been evaluated.
 func mkNaryFn(name string, n int, fn func([]sexpr_general) (sexpr_general, sexpr_error)) applicator {
-	return func(args []sexpr_general) (sexpr_general, sexpr_error) {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
 		if len(args) != n {
 			msg := fmt.Sprintf("Expected %d arguments, got %d", n, len(args))
 			return nil, evaluationError{name, msg}
@@ -180,241 +227,165 @@ func mkLazyReduce(
 	}
 }
 
-/////
-// Definitions of complicated things, too simple for inlining.  Mostly
-// macros, but a few others.
-/////
-type intOrFloat struct{
-	asint   int64
-	asfloat float64
-	isInt   bool
-}
-
-var (
-	zeroIntOrFloat = intOrFloat{0, 0.0, true}
-	oneIntOrFloat  = intOrFloat{1, 1.0, true}
-)
-
-func parseIntOrFloat(s sexpr_general) (*intOrFloat, error) {
-	var numberString string
-	switch s := s.(type) {
-	case sexpr_atom:
-		if s.typ != atomNumber {
-			msg := fmt.Sprintf("Atom %q is not a number", s)
-			return nil, errors.New(msg)
-		} else {
-			numberString = s.name
-		}
-	default:
-		msg := fmt.Sprintf("%q is not a number", s)
-		return nil, errors.New(msg)
-	}
-	// else, numberString is the thing to parse
-	ival, err := strconv.ParseInt(numberString, 10, 64)
-	if err == nil {
-		// It's an integer
-		return &intOrFloat{ival, float64(ival), true}, nil
-	}
-	// else
-	fval, err := strconv.ParseFloat(numberString, 64)
-	if err == nil {
-		// It's a float; the integer part is irrelevant
-		return &intOrFloat{0, fval, false}, nil
+// The reducers for "and" and "or".  They give #t or #f, never the
+// value of the last term.
+func reduceAnd(acc sexpr_general, val sexpr_general) (sexpr_general, bool) {
+	if isFalsey(val) {
+		return atomConstantFalse, true
 	} else {
-		return nil, err
+		return acc, false
 	}
 }
-
-func (i intOrFloat) String() string {
-	if i.isInt {
-		return fmt.Sprintf("%d", i.asint)
+func reduceOr(acc sexpr_general, val sexpr_general) (sexpr_general, bool) {
+	if !isFalsey(val) {
+		return atomConstantTrue, true
 	} else {
-		return fmt.Sprintf("%f", i.asfloat)
+		return acc, false
 	}
 }
-func (i intOrFloat) sexprize() sexpr_general {
-	return mkAtomNumber(fmt.Sprintf("%s", i))
-}
 
-// add m to n, destructively modifying n.  Like +=
-func (n *intOrFloat) increaseBy(m intOrFloat) {
-	n.asint   += m.asint
-	n.asfloat += m.asfloat
-	if n.isInt {
-		n.isInt = m.isInt
-	}
-}
-// multiply m to n, destructively modifying n.  Like *=
-func (n *intOrFloat) multiplyBy(m intOrFloat) {
-	n.asint   *= m.asint
-	n.asfloat *= m.asfloat
-	if n.isInt {
-		n.isInt = m.isInt
-	}
+/////
+// Definitions of complicated things, too simple for inlining.  Mostly
+// macros, but a few others.
+/////
+func mkTypePredicate(name string, test func(sexpr_general) bool) applicator {
+	return mkNaryFn(name, 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		if test(args[0]) {
+			return atomConstantTrue, nil
+		}
+		return atomConstantFalse, nil
+	})
 }
-// subtract m from n, destructively modifying n.  Like -=
-func (n *intOrFloat) decreaseBy(m intOrFloat) {
-	n.asint   -= m.asint
-	n.asfloat -= m.asfloat
-	if n.isInt {
-		n.isInt = m.isInt
-	}
+
+func mkAtomTypePredicate(name string, typ atomType) applicator {
+	return mkTypePredicate(name, func(s sexpr_general) bool {
+		a, ok := s.(sexpr_atom)
+		return ok && a.typ == typ
+	})
 }
-// divide m into n, destructively modifying n.  Like /=
-func (n *intOrFloat) divideBy(m intOrFloat) error {
-	if (m.isInt && m.asint == 0) || m.asfloat == 0 {
-		return errors.New("Divide by zero")
-	}
-	if n.isInt && m.isInt && n.asint % m.asint == 0 {
-		// Integer division is still possible
-		n.asint /= m.asint
-	} else {
-		n.isInt = false
-		n.asfloat /= m.asfloat
-	}
-	return nil
-}
-
-// raise n to the power m, desructively modifying n
-func (n *intOrFloat) toPower(m intOrFloat) {
-	if m.isInt {
-		intOrig := n.asint
-		if m.asint == 0 {
-			// TODO:  Assert n != 0
-			n.asint = 1
-			n.asfloat = 1
-			n.isInt = true
-			return
-		} else if m.asint > 0 {
-			for i := int64(1) ; i < m.asint ; i++ {
-				n.asint *= intOrig
-			}
-		} else {
-			// Fraction...
-			// TODO:  Assert n != 0
-			n.asfloat = 1/n.asfloat
-			for i := int64(1) ; i < -m.asint ; i++ {
-				n.asfloat /= float64(intOrig)
-			}
-			n.isInt = false
+
+// isList says whether s is a proper list, ending in ()
+func isList(s sexpr_general) bool {
+	for {
+		switch c := s.(type) {
+		case sexpr_cons:
+			s = c.cdr
+		default:
+			return s == sexpr_general(atomConstantNil)
 		}
 	}
-	n.asfloat = math.Pow(n.asfloat, m.asfloat)
-	if n.isInt {
-		n.isInt = m.isInt // Bleh... m is 1/2??
-	}
 }
 
-type arithmeticReducerFunction func(*intOrFloat, intOrFloat)
-
-func mkArithmeticReduce(
-	name string,
-	starter intOrFloat,
-	reducer arithmeticReducerFunction,
-) applicator {
-	return func(args []sexpr_general) (sexpr_general, sexpr_error) {
-		acc := starter // makes a copy!
-		for _, val := range args {
-			iorf, err := parseIntOrFloat(val)
-			if err != nil {
-				return nil, evaluationError{name, err.Error()}
+// mkAtomEquality makes boolean=? and string=?, which insist that every
+// argument be an atom of the one type
+func mkAtomEquality(name string, typ atomType, what string) applicator {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := checkArity(len(args), 2, -1) ; err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		for _, arg := range args {
+			if a, ok := arg.(sexpr_atom) ; !ok || a.typ != typ {
+				msg := fmt.Sprintf("%s is not %s", arg.Sprint(), what)
+				return nil, evaluationError{name, msg}
 			}
-			// else, destructively modify the accumulator
-			reducer(&acc, *iorf)
 		}
-		return acc.sexprize(), nil
+		for _, arg := range args[1:] {
+			if arg != args[0] {
+				return atomConstantFalse, nil
+			}
+		}
+		return atomConstantTrue, nil
 	}
 }
 
-func reducePlus(acc *intOrFloat, addend intOrFloat) {
-	acc.increaseBy(addend)
-}
-func reduceTimes(acc *intOrFloat, multiplicand intOrFloat) {
-	acc.multiplyBy(multiplicand)
-}
-
-func fnMinus(args []sexpr_general) (sexpr_general, sexpr_error) {
-	minuend, err := parseIntOrFloat(args[0])
-	if err != nil {
-		return nil, evaluationError{"-", err.Error()}
-	}
-	subtrahend, err := parseIntOrFloat(args[1])
-	if err != nil {
-		return nil, evaluationError{"-", err.Error()}
-	}
-	// else
-	minuend.decreaseBy(*subtrahend)
-	return minuend.sexprize(), nil
-}
-func fnDivide(args []sexpr_general) (sexpr_general, sexpr_error) {
-	divisor, err := parseIntOrFloat(args[0])
-	if err != nil {
-		return nil, evaluationError{"/", err.Error()}
-	}
-	dividend, err := parseIntOrFloat(args[1])
-	if err != nil {
-		return nil, evaluationError{"/", err.Error()}
-	}
-	// else
-	err = divisor.divideBy(*dividend)
-	if err != nil {
-		// Divide by zero
-		return nil, evaluationError{"/", err.Error()}
+// The equivalence predicates, from finest to coarsest.  isEq is
+// identity: atoms are values, so equal atoms are the same atom, but a
+// cons cell or a procedure is only ever itself.
+func isEq(a, b sexpr_general) bool {
+	switch a := a.(type) {
+	case sexpr_atom:
+		b, ok := b.(sexpr_atom)
+		return ok && a == b
+	case sexpr_cons:
+		b, ok := b.(sexpr_cons)
+		return ok && a.serialNumber == b.serialNumber
+	case func_expr:
+		b, ok := b.(func_expr)
+		return ok && a.serialNumber == b.serialNumber
+	case sexpr_vector:
+		b, ok := b.(sexpr_vector)
+		return ok && a == b
+	case sexpr_hash_table:
+		b, ok := b.(sexpr_hash_table)
+		return ok && a == b
+	case sexpr_record:
+		b, ok := b.(sexpr_record)
+		return ok && a == b
+	case sexpr_record_type:
+		b, ok := b.(sexpr_record_type)
+		return ok && a == b
+	case sexpr_port:
+		b, ok := b.(sexpr_port)
+		return ok && a == b
+	case sexpr_eof:
+		_, ok := b.(sexpr_eof)
+		return ok
+	case sexpr_environment:
+		b, ok := b.(sexpr_environment)
+		return ok && a.ctx == b.ctx
+	case sexpr_promise:
+		b, ok := b.(sexpr_promise)
+		return ok && a == b
+	case macro_expr:
+		// There's only one of each
+		b, ok := b.(macro_expr)
+		return ok && a.definition == b.definition
+	default:
+		return false
 	}
-	return divisor.sexprize(), nil
 }
-func fnExponent(args []sexpr_general) (sexpr_general, sexpr_error) {
-	base, err := parseIntOrFloat(args[0])
-	if err != nil {
-		return nil, evaluationError{"-", err.Error()}
-	}
-	exponent, err := parseIntOrFloat(args[1])
-	if err != nil {
-		return nil, evaluationError{"-", err.Error()}
+
+// isEqv is isEq, except that numbers are compared by value.  They
+// have to agree on exactness, though: 2 and 2.0 aren't eqv?.
+func isEqv(a, b sexpr_general) bool {
+	if isEq(a, b) {
+		return true
+	}
+	if n, err := parseIntOrFloat(a) ; err == nil {
+		if m, err := parseIntOrFloat(b) ; err == nil {
+			return n.isInt == m.isInt && n.compare(*m) == 0
+		}
 	}
-	// else
-	base.toPower(*exponent)
-	return base.sexprize(), nil
+	return false
 }
 
-func mkEqualAtomChecker(typ atomType) applicator {
-	return func(args []sexpr_general) (sexpr_general, sexpr_error) {
-		var atoms []sexpr_atom
-		for _, arg := range args {
-			switch arg := arg.(type) {
-			case sexpr_atom:
-				atoms = append(atoms, arg)
-			default:
-				return atomConstantFalse, nil
-			}
+// isEqual compares lists structurally, and everything else by isEqv
+func isEqual(a, b sexpr_general) bool {
+	for {
+		if va, ok := a.(sexpr_vector) ; ok {
+			vb, ok := b.(sexpr_vector)
+			return ok && equalVectors(va, vb)
 		}
-		// reaching here, we have atoms
-		// Assume at least one; n-ary 2 (probably)
-		if atoms[0].typ != typ {
-			return atomConstantFalse, nil
+		ca, ok := a.(sexpr_cons)
+		if !ok {
+			return isEqv(a, b)
 		}
-		// now check all the rest are the same
-		for i := 0 ; 1+i < len(atoms) ; i++ {
-			if atoms[1+i] != atoms[i] {
-				return atomConstantFalse, nil
-			}
+		cb, ok := b.(sexpr_cons)
+		if !ok || !isEqual(ca.car, cb.car) {
+			return false
 		}
-		return atomConstantTrue, nil
+		// Loop down the cdr, rather than recurse
+		a, b = ca.cdr, cb.cdr
 	}
 }
-var fnEqualNumber = mkEqualAtomChecker(atomNumber)
-var fnEqualSymbol = mkEqualAtomChecker(atomSymbol)
-func fnEqualAtom(args []sexpr_general) (sexpr_general, sexpr_error) {
-	if args[0] == atomConstantNil {
-		for i := 1 ; i < len(args) ; i++ {
-			if args[i] != atomConstantNil {
-				return atomConstantFalse, nil
-			}
+
+func mkEquivalence(same func(a, b sexpr_general) bool) func([]sexpr_general) (sexpr_general, sexpr_error) {
+	return func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		if same(args[0], args[1]) {
+			return atomConstantTrue, nil
 		}
-		return atomConstantTrue, nil
+		return atomConstantFalse, nil
 	}
-	// else
-	return fnEqualSymbol(args)
 }
 
 // evalQuote is a macro; it does not evaluate all its arguments
@@ -428,8 +399,47 @@ func evalQuote(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_
 	return args[0], nil
 }
 
-// evalDefine is a macro; it does not evaluate all its arguments
+// evalDefine is what "define" does when it turns up where an
+// expression belongs, as in (null? (define a 1)).  A definition has
+// no value, so that's a syntax error.  Definitions in their proper
+// places (top level, or the start of a body) are handled by
+// evalDefinition, which gets called before the macro would be.
 func evalDefine(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	return nil, evaluationError{
+		"define(syntax)",
+		fmt.Sprintf("Invalid context for definition %s",
+			mkCons(atomConstantDefine, lst).Sprint()),
+	}
+}
+
+// isDefinition says whether s is a (define ...) form, which it is if
+// the car is a symbol that (still) means "define" in ctx.
+func isDefinition(s sexpr_general, ctx *evaluationContext) bool {
+	return isForm(s, "define", ctx)
+}
+
+// isForm says whether s is a use of the special form name, whose
+// symbol hasn't been rebound in ctx
+func isForm(s sexpr_general, name string, ctx *evaluationContext) bool {
+	c, ok := s.(sexpr_cons)
+	if !ok {
+		return false
+	}
+	sym, ok := c.car.(sexpr_atom)
+	if !ok || sym.typ != atomSymbol {
+		return false
+	}
+	val, ok := ctx.lookup(sym)
+	if !ok {
+		return false
+	}
+	m, ok := val.(macro_expr)
+	return ok && m.definition == name
+}
+
+// evalDefinition binds a name in ctx, which had better be a
+// definition context.  lst is the cdr of the (define ...) form.
+func evalDefinition(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
 	args, err := unconsifyN(lst, 2)
 	if err != nil {
 		return nil, evaluationError{"define", err.Error()}
@@ -443,16 +453,14 @@ func evalDefine(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr
 			return nil, err
 		}
 		// log.Printf("DEFINE %q <-- %s", key, val)
-		err2 := globalEvaluationContext.bind(key, val)
+		err2 := ctx.bind(key, val)
 		if err2 != nil {
 			return nil, evaluationError{
 				"define(binding)",
 				err2.Error(),
 			}
 		}
-		return atomConstantNil, nil
-		// TODO:  "nil" isn't "Nil"; define has no value
-		// return nil, nil
+		return sexpr_definition{key}, nil
 	default:
 		return nil, evaluationError{
 			"define",
@@ -461,8 +469,44 @@ func evalDefine(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr
 	}
 }
 
+// evalBody evaluates the body of a "let" or "lambda" in ctx (which
+// should be a fresh frame).  Definitions (define or define-values) may
+// come first, and bind in ctx; then there must be at least one
+// expression.  The value is that of the last expression.
+func evalBody(name string, body []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	idx := 0
+	for ; idx < len(body) ; idx++ {
+		var err sexpr_error
+		if isDefinition(body[idx], ctx) {
+			_, err = evalDefinition(body[idx].(sexpr_cons).cdr, ctx)
+		} else if isForm(body[idx], "define-values", ctx) {
+			err = defineValues(body[idx].(sexpr_cons).cdr, ctx)
+		} else {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if idx == len(body) {
+		return nil, evaluationError{
+			name,
+			"Body has definitions but no expression",
+		}
+	}
+	// else
+	var ans sexpr_general
+	for ; idx < len(body) ; idx++ {
+		var err sexpr_error
+		if ans, err = evaluateWithContext(body[idx], ctx) ; err != nil {
+			return nil, err
+		}
+	}
+	return ans, nil
+}
+
 func evalLet(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
-	args, err := unconsifyN(lst, 2)
+	args, err := unconsifyAtLeastN(lst, 2)
 	if err != nil {
 		return nil, evaluationError{"let", err.Error()}
 	}
@@ -472,7 +516,7 @@ func evalLet(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_er
 		return nil, evaluationError{"let(args)", err.Error()}
 	}
 
-	newCtx := evaluationContext{make(symbolTable), ctx}
+	newCtx := ctx.extend()
 	for _, b := range bindings {
 		// log.Println("Create binding from", b)
 		kv, err := unconsifyN(b, 2)
@@ -499,17 +543,19 @@ func evalLet(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_er
 			}
 		}
 	}
-	return evaluateWithContext(args[1], &newCtx)
+	return evalBody("let", args[1:], newCtx)
 }
 
-func evalLambda(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
-	args, err := unconsifyN(lst, 2) // eventually "many"
+// parseLambda picks apart the cdr of a (lambda ...) form into the
+// parameter names and the body.  The definition is what the
+// resulting func_expr prints as.
+func parseLambda(lst sexpr_general) (bound []sexpr_atom, body []sexpr_general, definition string, serr sexpr_error) {
+	args, err := unconsifyAtLeastN(lst, 2)
 	if err != nil {
-		return nil, evaluationError{"lambda", err.Error()}
+		return nil, nil, "", evaluationError{"lambda", err.Error()}
 	}
-	var bound []sexpr_atom
 	if decl, unconsify_err := unconsify(args[0]) ; unconsify_err != nil {
-		return nil, evaluationError{
+		return nil, nil, "", evaluationError{
 			"lambda",
 			fmt.Sprintf("Strange arguments %q:", lst, unconsify_err.Error()),
 		}
@@ -521,10 +567,10 @@ func evalLambda(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr
 					bound = append(bound, v)
 				} else {
 					msg := fmt.Sprintf("Invalid parameter-name %q", v)
-					return nil, evaluationError{"lambda", msg}
+					return nil, nil, "", evaluationError{"lambda", msg}
 				}
 			default:
-				return nil, evaluationError{
+				return nil, nil, "", evaluationError{
 					"lambda",
 					fmt.Sprintf("invalid parameter list in (λ %s %s)",
 						args[0], args[1]),
@@ -533,16 +579,28 @@ func evalLambda(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr
 		}
 	}
 
-	body := args[1]
-	definition := fmt.Sprintf("(λ (%s) %s)", bound, args[1].Sprint())
-	apply := func(args []sexpr_general) (sexpr_general, sexpr_error) {
+	body = args[1:]
+	definition = fmt.Sprintf("(λ (%s) %s)", bound, sprintBody(body))
+	return bound, body, definition, nil
+}
+
+func evalLambda(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	bound, body, definition, err := parseLambda(lst)
+	if err != nil {
+		return nil, err
+	}
+	apply := func(args []sexpr_general, caller *evaluationContext) (sexpr_general, sexpr_error) {
 		if len(bound) != len(args) {
 			return nil, evaluationError{
 				fmt.Sprintf("%s", definition),
 				fmt.Sprintf("Evaluation with %d arguments, expected %d", len(args), len(bound)),
 			}
 		}
-		newCtx := &evaluationContext{make(symbolTable), ctx}
+		if err := ctx.interp.enter() ; err != nil {
+			return nil, err
+		}
+		defer ctx.interp.leave()
+		newCtx := ctx.extend()
 		for idx, sym := range bound {
 			if err := newCtx.bind(sym, args[idx]) ; err != nil {
 				return nil, evaluationError{
@@ -551,9 +609,9 @@ func evalLambda(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr
 				}
 			}
 		}
-		return evaluateWithContext(body, newCtx)
+		return evalBody(definition, body, newCtx)
 	}
-	return func_expr{definition, apply}, nil
+	return func_expr{definition, apply, false, nil, nil, false, nextSerialNumber()}, nil
 }
 
 // If none of the first-elements to "cond" are truthy, the eventual
@@ -0,0 +1,409 @@
+package sexpr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+)
+
+// Hash tables, after SRFI 69.  Each one compares keys with eq?, eqv?,
+// equal? (the default) or string=?, and hashes them to match: under
+// equal?, a list hashes by what's in it, not by which cons cells it's
+// made of.  Walking a table goes in the order the keys were added, so
+// that what programs print doesn't change from run to run.
+type sexpr_hash_table struct{
+	*hashTable
+}
+
+type hashTable struct{
+	equivalence string
+	same func(a, b sexpr_general) bool
+	buckets map[uint64][]*hashEntry
+	// Every entry, in the order added.  Deleted ones stay (marked)
+	// until there are enough of them to be worth sweeping up.
+	entries []*hashEntry
+	count int
+	serialNumber int64
+}
+
+type hashEntry struct{
+	key sexpr_general
+	value sexpr_general
+	deleted bool
+}
+
+// The equivalences a table can use
+var hashTableEquivalences = map[string]func(a, b sexpr_general) bool{
+	"eq?":      isEq,
+	"eqv?":     isEqv,
+	"equal?":   isEqual,
+	"string=?": isEq, // strings are atoms, compared by value
+}
+
+func mkHashTable(equivalence string) sexpr_hash_table {
+	return sexpr_hash_table{&hashTable{
+		equivalence: equivalence,
+		same: hashTableEquivalences[equivalence],
+		buckets: make(map[uint64][]*hashEntry),
+		serialNumber: nextSerialNumber(),
+	}}
+}
+
+func (t sexpr_hash_table) Sprint() string {
+	return fmt.Sprintf("#<hash-table %s size=%d>", t.equivalence, t.count)
+}
+
+// hasher feeds an S-expression to a hash, looking only at what the
+// table's equivalence looks at
+type hasher struct{
+	h hash.Hash64
+	numbers bool   // by value, as eqv? and equal? compare them
+	structure bool // pairs and vectors by contents, as equal? does
+	budget int     // how many parts to look at, at most
+}
+
+func (hs *hasher) writeInt(n int64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(n))
+	hs.h.Write(buf[:])
+}
+
+func (hs *hasher) add(s sexpr_general) {
+	// Anything past the budget is left out of the hash.  That only
+	// makes collisions likelier; it keeps a huge (or circular) key
+	// from taking forever.
+	if hs.budget <= 0 {
+		return
+	}
+	hs.budget -= 1
+	switch s := s.(type) {
+	case sexpr_atom:
+		if s.typ == atomNumber && hs.numbers {
+			if n, err := parseIntOrFloat(s) ; err == nil {
+				if n.isInt {
+					hs.h.Write([]byte{'i'})
+					hs.writeInt(n.asint)
+				} else {
+					// -0.0 is eqv? to 0.0, so it has to hash the same
+					f := n.asfloat
+					if f == 0 {
+						f = 0
+					}
+					hs.h.Write([]byte{'f'})
+					hs.writeInt(int64(math.Float64bits(f)))
+				}
+				return
+			}
+		}
+		hs.h.Write([]byte{'a', byte(s.typ)})
+		hs.h.Write([]byte(s.name))
+	case sexpr_cons:
+		if hs.structure {
+			hs.h.Write([]byte{'('})
+			hs.add(s.car)
+			hs.add(s.cdr)
+			return
+		}
+		hs.writeInt(s.serialNumber)
+	case sexpr_vector:
+		if hs.structure {
+			hs.h.Write([]byte{'#'})
+			hs.writeInt(int64(len(s.elements)))
+			for _, elt := range s.elements {
+				hs.add(elt)
+			}
+			return
+		}
+		hs.writeInt(s.serialNumber)
+	case func_expr:
+		hs.writeInt(s.serialNumber)
+	case sexpr_hash_table:
+		hs.writeInt(s.serialNumber)
+	case sexpr_record:
+		hs.writeInt(s.serialNumber)
+	case sexpr_record_type:
+		hs.writeInt(s.serialNumber)
+	case sexpr_port:
+		hs.writeInt(s.serialNumber)
+	case sexpr_promise:
+		hs.writeInt(s.serialNumber)
+	default:
+		// Macros and such: there's only one of each
+		hs.h.Write([]byte(s.Sprint()))
+	}
+}
+
+func (t *hashTable) hash(key sexpr_general) (uint64, error) {
+	if t.equivalence == "string=?" {
+		if a, ok := key.(sexpr_atom) ; !ok || a.typ != atomString {
+			return 0, fmt.Errorf("%s is not a string", key.Sprint())
+		}
+	}
+	hs := &hasher{
+		h: fnv.New64a(),
+		numbers: t.equivalence != "eq?",
+		structure: t.equivalence == "equal?",
+		budget: 1024,
+	}
+	hs.add(key)
+	if c, ok := key.(sexpr_cons) ; ok && hs.structure {
+		// Long lists that agree as far as the budget goes can still
+		// differ in length, or at the end
+		length, last := 1, c
+		for next, ok := last.cdr.(sexpr_cons) ; ok ; next, ok = last.cdr.(sexpr_cons) {
+			length, last = length + 1, next
+		}
+		hs.writeInt(int64(length))
+		hs.budget = 64
+		hs.add(last.car)
+	}
+	return hs.h.Sum64(), nil
+}
+
+// find gives the entry for key, if there is one, and the key's hash
+func (t *hashTable) find(key sexpr_general) (*hashEntry, uint64, error) {
+	h, err := t.hash(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, e := range t.buckets[h] {
+		if t.same(e.key, key) {
+			return e, h, nil
+		}
+	}
+	return nil, h, nil
+}
+
+func (t *hashTable) set(key, value sexpr_general, ctx *evaluationContext) error {
+	e, h, err := t.find(key)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		e.value = value
+		return nil
+	}
+	if err := ctx.interp.allocate(1) ; err != nil {
+		return err
+	}
+	e = &hashEntry{key: key, value: value}
+	t.buckets[h] = append(t.buckets[h], e)
+	t.entries = append(t.entries, e)
+	t.count += 1
+	return nil
+}
+
+func (t *hashTable) delete(key sexpr_general) error {
+	e, h, err := t.find(key)
+	if err != nil || e == nil {
+		return err
+	}
+	bucket := t.buckets[h]
+	for idx, other := range bucket {
+		if other == e {
+			bucket = append(bucket[:idx:idx], bucket[idx + 1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(t.buckets, h)
+	} else {
+		t.buckets[h] = bucket
+	}
+	e.deleted = true
+	t.count -= 1
+	if len(t.entries) > 2 * t.count + 8 {
+		var live []*hashEntry
+		for _, e := range t.entries {
+			if !e.deleted {
+				live = append(live, e)
+			}
+		}
+		t.entries = live
+	}
+	return nil
+}
+
+// live gives the entries in the table now, in order
+func (t *hashTable) live() []*hashEntry {
+	ans := make([]*hashEntry, 0, t.count)
+	for _, e := range t.entries {
+		if !e.deleted {
+			ans = append(ans, e)
+		}
+	}
+	return ans
+}
+
+func hashTableArgument(s sexpr_general) (sexpr_hash_table, error) {
+	t, ok := s.(sexpr_hash_table)
+	if !ok {
+		return t, fmt.Errorf("%s is not a hash table", s.Sprint())
+	}
+	return t, nil
+}
+
+func fnMakeHashTable(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	if len(args) == 0 {
+		return mkHashTable("equal?"), nil
+	}
+	if f, ok := args[0].(func_expr) ; ok && f.primitive {
+		if _, ok := hashTableEquivalences[f.definition] ; ok {
+			return mkHashTable(f.definition), nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not eq?, eqv?, equal? or string=?", args[0].Sprint())
+}
+
+// fnHashTableRef calls the thunk, if there is one, when the key isn't
+// there
+func fnHashTableRef(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	e, _, err := t.find(args[1])
+	switch {
+	case err != nil:
+		return nil, err
+	case e != nil:
+		return e.value, nil
+	case len(args) > 2:
+		thunk, err := procedureArgument(args[2])
+		if err != nil {
+			return nil, err
+		}
+		return thunk.apply(nil, ctx)
+	}
+	// else
+	return nil, fmt.Errorf("No key %s in %s", args[1].Sprint(), t.Sprint())
+}
+
+func fnHashTableRefDefault(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	e, _, err := t.find(args[1])
+	switch {
+	case err != nil:
+		return nil, err
+	case e != nil:
+		return e.value, nil
+	}
+	return args[2], nil
+}
+
+func fnHashTableSet(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := t.set(args[1], args[2], ctx) ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+func fnHashTableDelete(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := t.delete(args[1]) ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+func fnHashTableContains(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	e, _, err := t.find(args[1])
+	switch {
+	case err != nil:
+		return nil, err
+	case e != nil:
+		return atomConstantTrue, nil
+	}
+	return atomConstantFalse, nil
+}
+
+func fnHashTableCount(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkInt(int64(t.count)).sexprize(), nil
+}
+
+// mkHashTableLister makes hash-table-keys, hash-table-values and
+// hash-table->alist, which make a list with one item per entry
+func mkHashTableLister(item func(*hashEntry, *evaluationContext) (sexpr_general, sexpr_error)) func([]sexpr_general, *evaluationContext) (sexpr_general, error) {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		t, err := hashTableArgument(args[0])
+		if err != nil {
+			return nil, err
+		}
+		entries := t.live()
+		items := make([]sexpr_general, len(entries))
+		for idx, e := range entries {
+			var err sexpr_error
+			if items[idx], err = item(e, ctx) ; err != nil {
+				return nil, err
+			}
+		}
+		return ctx.consifyOnto(items, atomConstantNil)
+	}
+}
+
+// fnHashTableWalk calls the procedure with each key and value.  It
+// walks the entries there when it started, whatever the procedure does
+// to the table.
+func fnHashTableWalk(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	t, err := hashTableArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	f, err := procedureArgument(args[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range t.live() {
+		if _, err := f.apply([]sexpr_general{e.key, e.value}, ctx) ; err != nil {
+			return nil, err
+		}
+	}
+	return unspecified, nil
+}
+
+var hashTableFunctions = map[string]applicator {
+	"make-hash-table":         mkListFn("make-hash-table", 0, 1, fnMakeHashTable),
+	"hash-table?":             mkTypePredicate("hash-table?", func(s sexpr_general) bool {
+		_, ok := s.(sexpr_hash_table)
+		return ok
+	}),
+	"hash-table-ref":          mkListFn("hash-table-ref", 2, 3, fnHashTableRef),
+	"hash-table-ref/default":  mkListFn("hash-table-ref/default", 3, 3, fnHashTableRefDefault),
+	"hash-table-set!":         mkListFn("hash-table-set!", 3, 3, fnHashTableSet),
+	"hash-table-delete!":      mkListFn("hash-table-delete!", 2, 2, fnHashTableDelete),
+	"hash-table-contains?":    mkListFn("hash-table-contains?", 2, 2, fnHashTableContains),
+	"hash-table-count":        mkListFn("hash-table-count", 1, 1, fnHashTableCount),
+	"hash-table-keys":         mkListFn("hash-table-keys", 1, 1, mkHashTableLister(
+		func(e *hashEntry, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return e.key, nil
+		})),
+	"hash-table-values":       mkListFn("hash-table-values", 1, 1, mkHashTableLister(
+		func(e *hashEntry, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return e.value, nil
+		})),
+	"hash-table->alist":       mkListFn("hash-table->alist", 1, 1, mkHashTableLister(
+		func(e *hashEntry, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return ctx.mkCons(e.key, e.value)
+		})),
+	"hash-table-walk":         mkListFn("hash-table-walk", 2, 2, fnHashTableWalk),
+}
@@ -0,0 +1,163 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHashTables(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(make-hash-table)", "^#<hash-table equal\\? size=0>$" },
+		{ "(make-hash-table eq?)", "^#<hash-table eq\\? size=0>$" },
+		{ "(make-hash-table car)", "is not eq\\?, eqv\\?, equal\\? or string=\\?" },
+		{ "(make-hash-table (lambda (a b) #t))", "is not eq\\?, eqv\\?, equal\\? or string=\\?" },
+		{ "(hash-table? (make-hash-table))", "^#t$" },
+		{ "(hash-table? '())", "^#f$" },
+		{ "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-ref h 'a)", "^1$" },
+		{ "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-set! h 'a 2) (hash-table-ref h 'a)", "^2$" },
+		{ "(define h (make-hash-table)) (hash-table-ref h 'a)", "No key a in #<hash-table equal\\? size=0>" },
+		{ "(define h (make-hash-table)) (hash-table-ref h 'a (lambda () 'none))", "^none$" },
+		{ "(define h (make-hash-table)) (hash-table-ref/default h 'a 0)", "^0$" },
+		{ "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-contains? h 'a)", "^#t$" },
+		{ "(define h (make-hash-table)) (hash-table-contains? h 'a)", "^#f$" },
+		{ "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-delete! h 'a) (hash-table-contains? h 'a)", "^#f$" },
+		{ "(define h (make-hash-table)) (hash-table-delete! h 'a) (hash-table-count h)", "^0$" },
+		{ "(hash-table-ref '((a . 1)) 'a)", "is not a hash table" },
+		// Keys come out in the order they went in
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h 'c 3) (hash-table-set! h 'a 1) (hash-table-set! h 'b 2)
+		   (hash-table-keys h)`, `^\(c a b\)$` },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h 'c 3) (hash-table-set! h 'a 1) (hash-table-set! h 'b 2)
+		   (hash-table-delete! h 'a)
+		   (hash-table->alist h)`, `^\(\(c \. 3\) \(b \. 2\)\)$` },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h 'x 1) (hash-table-set! h 'y 2)
+		   (hash-table-values h)`, `^\(1 2\)$` },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h 'x 1) (hash-table-set! h 'y 2)
+		   (hash-table-walk h (lambda (k v) (hash-table-set! h k (* 10 v))))
+		   (hash-table->alist h)`, `^\(\(x \. 10\) \(y \. 20\)\)$` },
+		// Keys are the same when the table's equivalence says so
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h (list 'a "b" 3) 'found)
+		   (hash-table-ref h '(a "b" 3))`, "^found$" },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h (vector 1 '(2)) 'found)
+		   (hash-table-ref h #(1 (2)))`, "^found$" },
+		{ `(define h (make-hash-table eqv?))
+		   (hash-table-set! h (list 'a) 'found)
+		   (hash-table-ref/default h (list 'a) 'not-found)`, "^not-found$" },
+		{ `(define h (make-hash-table eqv?))
+		   (define key (list 'a))
+		   (hash-table-set! h key 'found)
+		   (hash-table-ref/default h key 'not-found)`, "^found$" },
+		{ `(define h (make-hash-table eqv?))
+		   (hash-table-set! h 100 'found)
+		   (hash-table-ref/default h (* 10 10) 'not-found)`, "^found$" },
+		{ `(define h (make-hash-table eqv?))
+		   (hash-table-set! h 0.0 'found)
+		   (hash-table-ref/default h -0.0 'not-found)`, "^found$" },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h -0.0 'found)
+		   (hash-table-ref/default h (- 0.0) 'not-found)`, "^found$" },
+		{ `(define h (make-hash-table))
+		   (hash-table-set! h 2 'exact)
+		   (hash-table-ref/default h 2.0 'inexact)`, "^inexact$" },
+		{ `(define h (make-hash-table eq?))
+		   (hash-table-set! h car 'car)
+		   (hash-table-ref h car)`, "^car$" },
+		{ `(define h (make-hash-table string=?))
+		   (hash-table-set! h "key" 1)
+		   (hash-table-ref h "key")`, "^1$" },
+		{ `(define h (make-hash-table string=?))
+		   (hash-table-set! h 'key 1)`, "key is not a string" },
+		// A circular key doesn't hang anything
+		{ `(define h (make-hash-table))
+		   (define v (vector 1 2))
+		   (vector-set! v 1 v)
+		   (hash-table-set! h v 'circular)
+		   (hash-table-ref h v)`, "^circular$" },
+		// Memoization, which is what they're for
+		{ `(define memo (make-hash-table))
+		   (define fib (lambda (n)
+		     (cond
+		       ((< n 2) n)
+		       ((hash-table-contains? memo n) (hash-table-ref memo n))
+		       (else
+		         (let ((ans (+ (fib (- n 1)) (fib (- n 2)))))
+		           (hash-table-set! memo n ans)
+		           ans)))))
+		   (fib 80)`, "^23416728348467685$" },
+		{ "(string=? \"a\" \"a\" \"a\")", "^#t$" },
+		{ "(string=? \"a\" \"b\")", "^#f$" },
+		{ "(string=? \"a\" 'a)", "a is not a string" },
+	}
+
+	checkBackends(t, tests, nil)
+}
+
+func TestHashTableDeletion(t *testing.T) {
+	interp := NewInterpreter()
+	lastResult(interp, `
+(define h (make-hash-table))
+(define fill (lambda (n)
+  (cond ((< n 0) #t)
+        (else (let () (hash-table-set! h n (* n n)) (fill (- n 1)))))))
+(define empty (lambda (n)
+  (cond ((< n 0) #t)
+        (else (let () (cond ((odd? n) (hash-table-delete! h n))) (empty (- n 1)))))))
+(fill 999)
+(empty 999)
+`)
+	if got := lastResult(interp, "(hash-table-count h)") ; got.Sprint() != "500" {
+		t.Errorf("count after deleting = %s, want 500", got.Sprint())
+	}
+	if got := lastResult(interp, "(hash-table-ref h 998)") ; got.Sprint() != "996004" {
+		t.Errorf("(hash-table-ref h 998) = %s, want 996004", got.Sprint())
+	}
+	got := lastResult(interp, "(hash-table-keys h)")
+	if ok, _ := regexp.MatchString(`^\(998 996 994 .* 2 0\)$`, got.Sprint()) ; !ok {
+		t.Errorf("keys after deleting = %s", got.Sprint())
+	}
+}
+
+func TestLongKeysHashApart(t *testing.T) {
+	numbers := func(n int, last string) sexpr_general {
+		items := make([]sexpr_general, n)
+		for idx := range items {
+			items[idx] = mkAtomNumber("0")
+		}
+		items[n - 1] = mkAtomNumber(last)
+		return consify(items)
+	}
+	table := mkHashTable("equal?")
+	hash := func(key sexpr_general) uint64 {
+		h, err := table.hash(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	keys := map[string]sexpr_general{
+		"300 long":               numbers(300, "0"),
+		"300 long, ending in 1":  numbers(300, "1"),
+		"2000 long":              numbers(2000, "0"),
+		"2001 long":              numbers(2001, "0"),
+		"2000 long, ending in 1": numbers(2000, "1"),
+	}
+	seen := make(map[uint64]string)
+	for name, key := range keys {
+		h := hash(key)
+		if other, ok := seen[h] ; ok {
+			t.Errorf("%s and %s hash the same", name, other)
+		}
+		seen[h] = name
+	}
+	if hash(numbers(2000, "1")) != hash(keys["2000 long, ending in 1"]) {
+		t.Errorf("Equal lists hash differently")
+	}
+}
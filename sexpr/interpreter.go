@@ -0,0 +1,303 @@
+package sexpr
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// An Interpreter owns a root evaluationContext (where "define"
+// writes) and the bookkeeping needed to keep untrusted code from
+// running away with the machine.  Each REPL gets its own, so
+// clients of scam_server don't step on each other.
+
+// Limits caps the resources a single top-level form may use.  A
+// zero value for any field means "no limit".
+type Limits struct {
+	MaxSteps  int64 // calls to the evaluator
+	MaxDepth  int   // nested procedure calls
+	MaxConses int64 // cons cells allocated through mkCons
+}
+
+// DefaultLimits limits nothing.  Deep recursion is only as deep as
+// the Go stack, which is plenty for anything but a runaway; servers
+// running code they don't trust should set limits of their own.
+var DefaultLimits = Limits{
+	MaxSteps:  0,
+	MaxDepth:  0,
+	MaxConses: 0,
+}
+
+// A Backend is a strategy for running top-level forms
+type Backend int
+const (
+	// Walk the S-expression with evaluateWithContext.  The default,
+	// until the others are faster on everything.
+	BackendTreeWalk Backend = iota
+	// Compile each form to Go closures (see compiler.go), then run them
+	BackendClosure
+	// Compile each form to bytecode (see bytecode.go), then run it on
+	// the stack machine in vm.go
+	BackendVM
+)
+
+type Interpreter struct {
+	root    *evaluationContext
+	limits  Limits
+	backend Backend
+
+	// The symbols bound to built-in functions and macros.  "define"
+	// refuses to rebind them unless allowRedefinition is set.
+	primitives        map[sexpr_atom]bool
+	allowRedefinition bool
+
+	// Bumped whenever the root context changes, so compiled code
+	// knows when its cached globals are stale
+	generation int
+
+	// Files may only be read from under fileRoot; with no fileRoot,
+	// they can't be read at all.  loading is the stack of files being
+	// loaded or included, innermost last.  Relative paths start from
+	// the innermost of them, or from directory.
+	fileRoot  string
+	directory string
+	loading   []string
+
+	// How many top-level evaluations are running inside others (by
+	// load, include or eval), rather than for the REPL
+	nested int
+
+	// Libraries by name, like "(scheme base)", and where to look for
+	// the ones that haven't been defined yet
+	libraries   map[string]*library
+	libraryPath []string
+
+	// The current ports: where read-char reads, and display and the
+	// rest write, unless they're told otherwise
+	input       sexpr_port
+	output      sexpr_port
+	errorOutput sexpr_port
+
+	// Counters, reset at the start of each top-level Evaluate
+	steps  int64
+	depth  int
+	conses int64
+}
+
+// The prelude is SCAM source evaluated into each new Interpreter,
+// for the things that are easier to write in SCAM than in Go
+//go:embed prelude.ss
+var Prelude string
+
+// NewInterpreter makes an Interpreter with the primitives and the
+// Prelude
+func NewInterpreter() *Interpreter {
+	interp := NewBareInterpreter()
+	if err := interp.LoadSource("prelude", Prelude) ; err != nil {
+		panic("The prelude is broken: " + err.Error())
+	}
+	return interp
+}
+
+// NewBareInterpreter makes an Interpreter with just the primitives.
+// Load a prelude of your own with LoadSource, if you like.
+func NewBareInterpreter() *Interpreter {
+	interp := &Interpreter{
+		limits:     DefaultLimits,
+		primitives: make(map[sexpr_atom]bool),
+		// No input unless the host gives some: a REPL's input is
+		// the program
+		input:       mkInputPort("input", strings.NewReader(""), true),
+		output:      mkOutputPort("output", os.Stdout),
+		errorOutput: mkOutputPort("error", os.Stderr),
+	}
+	interp.root = &evaluationContext{
+		make(symbolTable),
+		nil,
+		interp,
+		nil,
+		nil,
+	}
+
+	// Pre-make all the primitive symbols.  Maybe these need to be their
+	// own things; we'll see how Evaluate goes
+	for str, eva := range primitiveMacros {
+		interp.root.bind(
+			mkAtomSymbol(str),
+			macro_expr{str, eva},
+		)
+	}
+	for _, table := range primitiveFunctionTables {
+		for str, eva := range table {
+			interp.root.bind(
+				mkAtomSymbol(str),
+				func_expr{str, eva, true, nil, nil, false, nextSerialNumber()},
+			)
+		}
+	}
+	interp.bindPortParameters()
+	// Now that they're bound, protect them
+	for key := range interp.root.sym {
+		interp.primitives[key] = true
+	}
+	interp.libraries = make(map[string]*library)
+	interp.addBaseLibrary()
+	return interp
+}
+
+// LoadSource evaluates the forms of src in turn.  It stops at the
+// first error, parse or evaluation, and returns it.  Definitions made
+// this way aren't primitives, so they can be redefined.
+func (i *Interpreter) LoadSource(name string, src string) error {
+	p, sexprs := Parse(name, mkRuneChannel(src))
+	for sx := range sexprs {
+		if err, ok := i.Evaluate(sx).(sexpr_error) ; ok {
+			// Let the parser finish
+			for range sexprs {
+			}
+			return err
+		}
+	}
+	return p.Err()
+}
+
+func (i *Interpreter) SetBackend(b Backend) { i.backend = b }
+
+// SetFileRoot lets SCAM code read files from under dir (with "load",
+// for instance).  It's "" by default, which means no files at all:
+// scam_server shouldn't hand out the server's disk.
+func (i *Interpreter) SetFileRoot(dir string) { i.fileRoot = dir }
+
+// SetDirectory says where relative paths start, when no file is being
+// loaded.  The default, "", is the working directory.
+func (i *Interpreter) SetDirectory(dir string) { i.directory = dir }
+func (i *Interpreter) SetLimits(l Limits) { i.limits = l }
+
+// SetInput, SetOutput and SetErrorOutput give the current input,
+// output and error ports.  By default there's no input, and output
+// goes to os.Stdout and os.Stderr; a REPL sends both to its own out.
+func (i *Interpreter) SetInput(r io.Reader) { i.input = mkInputPort("input", r, false) }
+func (i *Interpreter) SetOutput(w io.Writer) { i.output = mkOutputPort("output", w) }
+func (i *Interpreter) SetErrorOutput(w io.Writer) { i.errorOutput = mkOutputPort("error", w) }
+func (i *Interpreter) Limits() Limits { return i.limits }
+
+// SetAllowRedefinition lets "define" rebind primitives like null?.
+// It's off by default, because doing it by accident is confusing.
+func (i *Interpreter) SetAllowRedefinition(b bool) { i.allowRedefinition = b }
+
+// isProtected says whether binding key in the context e would clobber
+// a primitive.  Only the root context is protected; a "let" or
+// "lambda" may shadow anything it likes.
+func (i *Interpreter) isProtected(e *evaluationContext, key sexpr_atom) bool {
+	return i != nil && e == i.root && !i.allowRedefinition && i.primitives[key]
+}
+
+// Evaluate evaluates one top-level form in the Interpreter's root
+// context.  Errors come back as S-expressions, because they can
+// Sprint.
+func (i *Interpreter) Evaluate(s sexpr_general) sexpr_general {
+	i.steps, i.depth, i.conses = 0, 0, 0
+	if val, err := i.evaluateTopLevel(s) ; err != nil {
+		if _, ok := err.(continuationInvoked) ; ok {
+			// Nobody was left to catch it
+			return evaluationError{"call/cc", "Continuation invoked outside of its extent"}
+		}
+		return err
+	} else {
+		return val
+	}
+}
+
+// evaluateTopLevel evaluates s in the root context, with whichever
+// backend is chosen, but without resetting the counters (so a form
+// that loads a file pays for what's in it)
+func (i *Interpreter) evaluateTopLevel(s sexpr_general) (sexpr_general, sexpr_error) {
+	var eval compiled
+	switch {
+	case i.backend == BackendClosure:
+		eval = (&compiler{i}).compileTopLevel(s)
+	case i.backend == BackendVM:
+		if tmpl, err := (&compiler{i}).assembleTopLevel(s) ; err == nil {
+			eval = func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+				return i.runTopLevel(tmpl)
+			}
+		} else {
+			// Too big for the instruction format
+			eval = (&compiler{i}).compileTopLevel(s)
+		}
+	case isDefinition(s, i.root):
+		// The top level is a definition context
+		eval = func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return evalDefinition(s.(sexpr_cons).cdr, ctx)
+		}
+	default:
+		eval = func(ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return evaluateWithContext(s, ctx)
+		}
+	}
+	return eval(i.root)
+}
+
+// step charges one evaluation step.  A nil Interpreter (a context
+// built by hand, say) is never limited.
+func (i *Interpreter) step() sexpr_error {
+	if i == nil {
+		return nil
+	}
+	i.steps += 1
+	if i.limits.MaxSteps > 0 && i.steps > i.limits.MaxSteps {
+		return evaluationError{
+			"eval",
+			fmt.Sprintf("Exceeded the limit of %d evaluation steps", i.limits.MaxSteps),
+		}
+	}
+	return nil
+}
+
+// enter records a procedure call; every successful enter must be
+// paired with a leave.
+func (i *Interpreter) enter() sexpr_error {
+	if i == nil {
+		return nil
+	}
+	if i.limits.MaxDepth > 0 && i.depth >= i.limits.MaxDepth {
+		return evaluationError{
+			"apply",
+			fmt.Sprintf("Exceeded the maximum call depth of %d", i.limits.MaxDepth),
+		}
+	}
+	i.depth += 1
+	return nil
+}
+
+func (i *Interpreter) leave() {
+	if i != nil {
+		i.depth -= 1
+	}
+}
+
+// bytesPerCell is how much text counts as one cons cell, for what
+// string ports hold
+const bytesPerCell = 16
+
+// allocateText charges for n bytes of text, as cons cells
+func (i *Interpreter) allocateText(n int) sexpr_error {
+	return i.allocate(int64((n + bytesPerCell - 1) / bytesPerCell))
+}
+
+// allocate charges n cons cells
+func (i *Interpreter) allocate(n int64) sexpr_error {
+	if i == nil {
+		return nil
+	}
+	i.conses += n
+	if i.limits.MaxConses > 0 && i.conses > i.limits.MaxConses {
+		return evaluationError{
+			"cons",
+			fmt.Sprintf("Exceeded the limit of %d cons cells", i.limits.MaxConses),
+		}
+	}
+	return nil
+}
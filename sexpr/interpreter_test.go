@@ -0,0 +1,239 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+// lastResult evaluates each form of input, and gives the last value
+func lastResult(interp *Interpreter, input string) sexpr_general {
+	_, sexprs := Parse("test", mkRuneChannel(input))
+	var got sexpr_general
+	for sx := range sexprs {
+		got = interp.Evaluate(sx)
+	}
+	return got
+}
+
+// checkBackends runs each test in a new Interpreter with every backend,
+// after setup (if there is one), and checks that the last result
+// matches test.want
+func checkBackends(t *testing.T, tests []struct{ input, want string }, setup func(*Interpreter)) {
+	t.Helper()
+	for _, backend := range []Backend{BackendTreeWalk, BackendClosure, BackendVM} {
+		for _, test := range tests {
+			interp := NewInterpreter()
+			interp.SetBackend(backend)
+			if setup != nil {
+				setup(interp)
+			}
+			got := lastResult(interp, test.input)
+			if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+				t.Errorf("Backend %d: Evaluate[%s] = %q, want %q",
+					backend, test.input, got.Sprint(), test.want)
+			}
+		}
+	}
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	loop := `
+(define loop (lambda (n) (loop (+ n 1))))
+(loop 0)
+`
+	grow := `
+(define grow (lambda (l) (grow (cons 1 l))))
+(grow '())
+`
+	// A string port's text doubles each time round
+	double := `
+(define s (open-output-string))
+(write-string "x" s)
+(define double
+  (lambda (n)
+    (cond
+      ((= n 0) 'done)
+      (else (let ((ignore (write-string (get-output-string s) s))) (double (- n 1)))))))
+`
+	tests := []struct{
+		limits Limits
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ Limits{MaxSteps: 100}, loop, "Exception in eval: Exceeded the limit of 100 evaluation steps" },
+		{ Limits{MaxDepth: 50}, loop, "Exception in apply: Exceeded the maximum call depth of 50" },
+		{ Limits{MaxDepth: 50}, grow, "Exception in apply: Exceeded the maximum call depth of 50" },
+		{ Limits{MaxConses: 10, MaxDepth: 50}, grow, "Exception in cons: Exceeded the limit of 10 cons cells" },
+		{ Limits{MaxConses: 3}, "(cons 1 (cons 2 (cons 3 '())))", `^\(1 2 3\)$` },
+		{ Limits{MaxConses: 2}, "(cons 1 (cons 2 (cons 3 '())))", "Exceeded the limit of 2 cons cells" },
+		{ Limits{MaxSteps: 1000000, MaxConses: 1000000}, double + "(double 9) (get-output-string s)", `^"x{512}"$` },
+		{ Limits{MaxSteps: 1000000, MaxConses: 1000000}, double + "(double 40)", "Exceeded the limit of 1000000 cons cells" },
+		{ Limits{MaxConses: 10}, `(with-output-to-string (lambda () (write "a long string, longer than ten cells, or 160 bytes, which is quite a lot of text to write in one go, but then that is the point of the exercise, isn't it")))`, "Exceeded the limit of 10 cons cells" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetLimits(test.limits)
+		_, sexprs := Parse("test", mkRuneChannel(test.input))
+		var got sexpr_general
+		for sx := range sexprs {
+			got = interp.Evaluate(sx)
+		}
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] with %+v = %q, want %q",
+				test.input, test.limits, got.Sprint(), test.want,
+			)
+		}
+	}
+}
+
+func TestDefaultLimits(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		// The closure backend has no tail calls, so this is deep for it
+		{ `(define loop (lambda (n) (cond ((zero? n) 'done) (else (loop (- n 1))))))
+		   (loop 100000)`, "^done$" },
+		{ `(define count (lambda (n) (cond ((zero? n) 0) (else (+ 1 (count (- n 1)))))))
+		   (count 100000)`, "^100000$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
+
+func TestInterpreterLimitsReset(t *testing.T) {
+	// The counters are per top-level form, so a long session
+	// doesn't exhaust them
+	interp := NewInterpreter()
+	interp.SetLimits(Limits{MaxSteps: 10, MaxConses: 1})
+	for i := 0 ; i < 5 ; i++ {
+		_, sexprs := Parse("test", mkRuneChannel("(cons 1 2)"))
+		for sx := range sexprs {
+			if got := interp.Evaluate(sx) ; got.Sprint() != "(1 . 2)" {
+				t.Errorf("Evaluate #%d gave %s, want (1 . 2)", i, got.Sprint())
+			}
+		}
+	}
+}
+
+func TestInterpretersAreSeparate(t *testing.T) {
+	one := NewInterpreter()
+	two := NewInterpreter()
+	_, sexprs := Parse("test", mkRuneChannel("(define a 1)"))
+	for sx := range sexprs {
+		one.Evaluate(sx)
+	}
+	_, sexprs = Parse("test", mkRuneChannel("a"))
+	for sx := range sexprs {
+		if got := one.Evaluate(sx) ; got != atomone {
+			t.Errorf("a in the defining interpreter = %s, want 1", got.Sprint())
+		}
+		if _, ok := two.Evaluate(sx).(evaluationError) ; !ok {
+			t.Error("a leaked into a second interpreter")
+		}
+	}
+}
+
+func TestPrimitiveProtection(t *testing.T) {
+	tests := []struct{
+		allow bool
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ false, "(define null? (lambda (x) #f))", "Cannot redefine primitive null\\?" },
+		{ false, "(define null? (lambda (x) #f)) (null? '())", "^#t$" },
+		{ false, "(define lambda 1)", "Cannot redefine primitive lambda" },
+		{ false, "(let ([car cdr]) (car '(1 2)))", `^\(2\)$` },
+		{ false, "((lambda (cons) cons) 1)", "^1$" },
+		{ true, "(define null? (lambda (x) #f)) (null? '())", "^#f$" },
+		{ true, "(define car (lambda (x) x)) (primitive? car)", "^#f$" },
+		{ false, "(primitive? car)", "^#t$" },
+		{ false, "(primitive? cond)", "^#t$" },
+		{ false, "(primitive? (lambda (x) x))", "^#f$" },
+		{ false, "(define add1 (lambda (x) (+ x 1))) (primitive? add1)", "^#f$" },
+		{ false, "(primitive? 'car)", "^#f$" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetAllowRedefinition(test.allow)
+		_, sexprs := Parse("test", mkRuneChannel(test.input))
+		var got sexpr_general
+		for sx := range sexprs {
+			got = interp.Evaluate(sx)
+		}
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] (allow=%v) = %q, want %q",
+				test.input, test.allow, got.Sprint(), test.want,
+			)
+		}
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	tests := []struct{
+		bare bool
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ false, "(add1 41)", "^42$" },
+		{ false, "(sub1 0)", "^-1$" },
+		{ false, "(lat? '(a b c))", "^#t$" },
+		{ false, "(lat? '(a (b) c))", "^#f$" },
+		{ false, "(member? 'b '(a b c))", "^#t$" },
+		{ false, "(primitive? add1)", "^#f$" },
+		{ false, "(define add1 (lambda (n) (+ n 2))) (add1 1)", "^3$" },
+		{ true, "(add1 1)", "add1\\) is not bound" },
+		{ true, "(car '(1 2))", "^1$" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		if test.bare {
+			interp = NewBareInterpreter()
+		}
+		got := lastResult(interp, test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] (bare=%v) = %q, want %q",
+				test.input, test.bare, got.Sprint(), test.want,
+			)
+		}
+	}
+}
+
+func TestLoadSource(t *testing.T) {
+	tests := []struct{
+		src string
+		want string // a regexp matching the error, or "" for none
+	} {
+		{ "(define double (lambda (n) (* n 2)))", "" },
+		{ "(define x 1) (car x) (define y 2)", "car" },
+		{ "(define x 1))", "LPAREN" },
+	}
+
+	for _, test := range tests {
+		err := NewBareInterpreter().LoadSource("test", test.src)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("LoadSource[%s] = %v, want no error", test.src, err)
+		case test.want == "":
+		case err == nil:
+			t.Errorf("LoadSource[%s] = no error, want %q", test.src, test.want)
+		default:
+			if ok, _ := regexp.MatchString(test.want, err.Error()) ; !ok {
+				t.Errorf("LoadSource[%s] = %q, want %q", test.src, err.Error(), test.want)
+			}
+		}
+	}
+
+	// What loaded stays loaded, up to the error
+	interp := NewBareInterpreter()
+	interp.LoadSource("test", "(define x 1) (car x) (define y 2)")
+	if got := lastResult(interp, "x") ; got.Sprint() != "1" {
+		t.Errorf("x = %s, want 1", got.Sprint())
+	}
+	if _, ok := lastResult(interp, "y").(evaluationError) ; !ok {
+		t.Error("y was defined after the error")
+	}
+}
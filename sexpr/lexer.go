@@ -3,6 +3,7 @@ package sexpr
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -30,7 +31,9 @@ const (
 	itemSymbol                // abc
 	itemBoolean               // #t or #f
 	itemWhitespace            // ... maybe not needed
-	itemQuotationMark         // " not yet implemented
+	itemString                // "abc", quotes, escapes and all
+	itemCharacter             // #\a, #\space or #\x41 (without the #)
+	itemVectorStart           // #( (without the #)
 )
 
 func (i item) String() string {
@@ -44,7 +47,9 @@ func (i item) String() string {
 	case itemSymbol: return fmt.Sprintf("SYMBOL(%s)", i.val)
 	case itemBoolean: return fmt.Sprintf("BOOL(%s)", i.val)
 	case itemWhitespace: return "WHITESPACE"
-	case itemQuotationMark: return "DOUBLEQUOTE"
+	case itemString: return fmt.Sprintf("STRING(%s)", i.val)
+	case itemCharacter: return fmt.Sprintf("CHAR(%s)", i.val)
+	case itemVectorStart: return "VECTOR"
 	case itemError: return fmt.Sprintf("ERROR(%s)", i.val)
 	default:
 		panic(fmt.Sprintf("Unrecognized token in 'String': {%v, $v}", i.typ, i.val))
@@ -241,13 +246,9 @@ func lex(name string, src <-chan rune) (*lexer, chan item) {
 ////
 // Helpers that "define" the language
 ////
+// These are variables, not an init(), because the default Interpreter
+// lexes its prelude while the package is being initialized
 var (
-	isPartOfASymbol runeTester
-	looksLikeSymbolTerminator runeTester
-	looksLikeNumberStart runeTester
-)
-
-func init() {
 	isPartOfASymbol = func() runeTester {
 		// Oversimplified from https://www.scheme.com/tspl4/grammar.html#grammar:symbols
 		l := mkLookupFunc("*+-^$/><=?") // "-" and "?" are punctuation
@@ -270,7 +271,7 @@ func init() {
 		}
 	}()
 	looksLikeNumberStart = mkLookupFunc("+-")
-}
+)
 ////
 // The state functions
 ////
@@ -299,7 +300,9 @@ func lexText(l *lexer) stateFn {
 			case looksLikeSymbolTerminator(peek):
 				return lexSymbol
 			default:
-				l.backup() // We read the number start; put it back
+				// lexNumber's sign is optional, so it doesn't
+				// mind that we've read it already.  (Backing up
+				// here, after the peek, would block.)
 				return lexNumber
 			}
 		case '0' <= r && r <= '9':
@@ -311,9 +314,11 @@ func lexText(l *lexer) stateFn {
 				return l.errorf("invalid quote sequence %q", l.input[l.start:])
 			}
 			l.emit(itemSingleQuote)
+		case r == '"':
+			return lexString
 		case r == '#':
 			l.ignore() // Consume
-			return lexBoolean
+			return lexHash
 		case isPartOfASymbol(r):
 			// No backup; lexSymbol expects to have read one
 			return lexSymbol
@@ -364,6 +369,40 @@ func lexSymbol(l *lexer) stateFn {
 	return lexText
 }
 
+// lexHash sorts out what a '#' (read and ignored already) starts
+func lexHash(l *lexer) stateFn {
+	switch l.peek() {
+	case '\\':
+		l.next()
+		return lexCharacter
+	case '(', '[':
+		l.next()
+		l.emit(itemVectorStart)
+		return lexText
+	}
+	return lexBoolean
+}
+
+// lexCharacter reads the rest of a character, after the #\.  That's
+// one character of any kind, or a run of them that names one.
+func lexCharacter(l *lexer) stateFn {
+	r := l.next()
+	if r == eof {
+		return l.errorf("Unterminated character #%s", l.input[l.start:l.pos])
+	}
+	if isPartOfASymbol(r) {
+		l.acceptRunPredicate(isPartOfASymbol)
+	}
+	if !looksLikeSymbolTerminator(l.peek()) {
+		return l.errorf("Unrecognized character #%s", l.input[l.start:])
+	}
+	if _, err := parseCharacter(l.input[l.start + 1:l.pos]) ; err != nil {
+		return l.errorf("%s", err.Error())
+	}
+	l.emit(itemCharacter)
+	return lexText
+}
+
 func lexBoolean(l *lexer) stateFn {
 	if !l.accept("tf") {
 		return l.errorf("Unrecognized boolean %q",
@@ -381,6 +420,62 @@ func lexBoolean(l *lexer) stateFn {
 	return lexText
 }
 
+// lexString reads up to the closing quote; we've already read the
+// opening one.  A backslash protects whatever follows it, and
+// unescapeString sorts out what it means.
+func lexString(l *lexer) stateFn {
+	for {
+		switch l.next() {
+		case eof:
+			return l.errorf("Unterminated string %s", l.input[l.start:l.pos])
+		case '\\':
+			if l.next() == eof {
+				return l.errorf("Unterminated string %s", l.input[l.start:l.pos])
+			}
+		case '"':
+			l.emit(itemString)
+			return lexText
+		}
+	}
+}
+
+// unescapeString turns the text of a string token (without its
+// quotes) into the string it stands for
+func unescapeString(s string) (string, error) {
+	var b strings.Builder
+	for idx := 0 ; idx < len(s) ; idx++ {
+		if s[idx] != '\\' {
+			b.WriteByte(s[idx])
+			continue
+		}
+		// else, an escape; the lexer made sure something follows
+		idx += 1
+		switch s[idx] {
+		case 'n': b.WriteByte('\n')
+		case 't': b.WriteByte('\t')
+		case 'r': b.WriteByte('\r')
+		case 'a': b.WriteByte('\a')
+		case '0': b.WriteByte(0)
+		case '\\', '"': b.WriteByte(s[idx])
+		case 'x':
+			// \x41; is A
+			end := strings.IndexByte(s[idx:], ';')
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated escape in %q", s)
+			}
+			code, err := strconv.ParseInt(s[idx+1:idx+end], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("Bad escape \\%s in %q", s[idx:idx+end+1], s)
+			}
+			b.WriteRune(rune(code))
+			idx += end
+		default:
+			return "", fmt.Errorf("Unknown escape \\%c in %q", s[idx], s)
+		}
+	}
+	return b.String(), nil
+}
+
 func lexComment(l *lexer) stateFn {
 	l.acceptUntilPredicate(mkLookupFunc("\n"))
 	l.emit(itemComment)
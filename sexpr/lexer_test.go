@@ -38,6 +38,43 @@ func TestSimpleLexer(t *testing.T) {
 				{ itemEOF, ""},
 			},
 		},
+		{
+			"-7 -",
+			[]item {
+				{ itemNumber, "-7" },
+				{ itemSymbol, "-" },
+				{ itemEOF, ""},
+			},
+		},
+		{
+			`"a \"b\"" "("`,
+			[]item {
+				{ itemString, `"a \"b\""` },
+				{ itemString, `"("` },
+				{ itemEOF, ""},
+			},
+		},
+		{
+			`#\a #\space #\( #\x41)`,
+			[]item {
+				{ itemCharacter, `\a` },
+				{ itemCharacter, `\space` },
+				{ itemCharacter, `\(` },
+				{ itemCharacter, `\x41` },
+				{ itemRparen, ")" },
+				{ itemEOF, ""},
+			},
+		},
+		{
+			"#(1 #t)",
+			[]item {
+				{ itemVectorStart, "(" },
+				{ itemNumber, "1" },
+				{ itemBoolean, "t" },
+				{ itemRparen, ")" },
+				{ itemEOF, ""},
+			},
+		},
 		{
 			"o+",
 			[]item {
@@ -0,0 +1,367 @@
+package sexpr
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Libraries, after R7RS section 5.6:
+//
+//   (define-library (stats basic)
+//     (export mean (rename sum total))
+//     (import (scheme base))
+//     (begin
+//       (define sum (lambda (l) (fold-left + 0 l)))
+//       (define mean (lambda (l) (/ (sum l) (length l))))))
+//
+//   (import (prefix (stats basic) stats:))
+//
+// Each library gets an evaluationContext of its own, which starts out
+// empty (not even "define" is there until it's imported).  Library
+// bodies always run on the tree-walking evaluator, because the
+// compilers assume every global lives in the root context.
+//
+// A library that isn't defined yet is looked for on the library path:
+// (stats basic) is stats/basic.sld, or stats/basic.ss, under one of
+// its directories.
+
+type library struct{
+	name string
+	env *evaluationContext
+	// What each exported name is called inside the library
+	exports map[sexpr_atom]sexpr_atom
+}
+
+// An importSet maps names to the values they'll be bound to
+type importSet map[sexpr_atom]sexpr_general
+
+// SetLibraryPath gives the directories "import" searches for libraries
+// it doesn't know yet.  Relative ones start from the Interpreter's
+// directory.
+func (i *Interpreter) SetLibraryPath(dirs []string) { i.libraryPath = dirs }
+
+// addBaseLibrary makes (scheme base), which is all the primitives
+func (i *Interpreter) addBaseLibrary() {
+	lib := &library{
+		name: "(scheme base)",
+		env: &evaluationContext{make(symbolTable), nil, i, nil, nil},
+		exports: make(map[sexpr_atom]sexpr_atom),
+	}
+	for key := range i.primitives {
+		val, _ := i.root.get(key)
+		lib.env.sym[key] = val
+		lib.exports[key] = key
+	}
+	i.libraries[lib.name] = lib
+}
+
+// libraryName checks that name is a library name, a list of symbols
+// and integers, and gives the key it's filed under
+func libraryName(name sexpr_general) (string, []string, error) {
+	parts, err := unconsify(name)
+	if err != nil || len(parts) == 0 {
+		return "", nil, fmt.Errorf("%s is not a library name", name.Sprint())
+	}
+	var names []string
+	for _, part := range parts {
+		a, ok := part.(sexpr_atom)
+		if !ok || (a.typ != atomSymbol && !isLibraryNumber(a)) {
+			return "", nil, fmt.Errorf("%s is not a library name", name.Sprint())
+		}
+		names = append(names, a.name)
+	}
+	return name.Sprint(), names, nil
+}
+
+// isLibraryNumber is true of the exact, non-negative integers that
+// may be parts of library names
+func isLibraryNumber(a sexpr_atom) bool {
+	_, err := strconv.ParseUint(a.name, 10, 64)
+	return a.typ == atomNumber && err == nil
+}
+
+// findLibrary gives the library called name, loading it from the
+// library path if need be
+func (i *Interpreter) findLibrary(name sexpr_general) (*library, sexpr_error) {
+	key, parts, err := libraryName(name)
+	if err != nil {
+		return nil, evaluationError{"import", err.Error()}
+	}
+	if lib, ok := i.libraries[key] ; ok {
+		return lib, nil
+	}
+	for _, dir := range i.libraryPath {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(i.directory, dir)
+		}
+		base := filepath.Join(append([]string{dir}, parts...)...)
+		for _, ext := range []string{".sld", ".ss"} {
+			path, err := filepath.Abs(base + ext)
+			if err != nil || !fileExists(path) {
+				continue
+			}
+			if _, err := fnLoad([]sexpr_general{mkAtomString(path)}, i.root) ; err != nil {
+				return nil, err
+			}
+			if lib, ok := i.libraries[key] ; ok {
+				return lib, nil
+			}
+			msg := fmt.Sprintf("%s doesn't define the library %s", path, key)
+			return nil, evaluationError{"import", msg}
+		}
+	}
+	return nil, evaluationError{"import", fmt.Sprintf("No library named %s", key)}
+}
+
+// importSetNames checks that the rest of an import set are symbols
+func importSetNames(op string, items []sexpr_general) ([]sexpr_atom, sexpr_error) {
+	var names []sexpr_atom
+	for _, item := range items {
+		a, ok := item.(sexpr_atom)
+		if !ok || a.typ != atomSymbol {
+			msg := fmt.Sprintf("%s is not an identifier, in %s", item.Sprint(), op)
+			return nil, evaluationError{"import", msg}
+		}
+		names = append(names, a)
+	}
+	return names, nil
+}
+
+func missingImport(op string, name sexpr_atom) sexpr_error {
+	msg := fmt.Sprintf("%s is not in the import set, in %s", name.Sprint(), op)
+	return evaluationError{"import", msg}
+}
+
+// resolveImportSet works out what an import set (a library name, or
+// only/except/prefix/rename of an import set) would import
+func (i *Interpreter) resolveImportSet(set sexpr_general) (importSet, sexpr_error) {
+	parts, err := unconsify(set)
+	if err != nil || len(parts) == 0 {
+		return nil, evaluationError{"import", fmt.Sprintf("%s is not an import set", set.Sprint())}
+	}
+	op := ""
+	if a, ok := parts[0].(sexpr_atom) ; ok && len(parts) >= 2 {
+		if _, nested := parts[1].(sexpr_cons) ; nested {
+			op = a.name
+		}
+	}
+	if op != "only" && op != "except" && op != "prefix" && op != "rename" {
+		lib, err := i.findLibrary(set)
+		if err != nil {
+			return nil, err
+		}
+		ans := make(importSet)
+		for external, internal := range lib.exports {
+			val, _ := lib.env.get(internal)
+			ans[external] = val
+		}
+		return ans, nil
+	}
+	// else
+	inner, serr := i.resolveImportSet(parts[1])
+	if serr != nil {
+		return nil, serr
+	}
+	ans := make(importSet)
+	switch op {
+	case "only":
+		names, err := importSetNames(op, parts[2:])
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			val, ok := inner[name]
+			if !ok {
+				return nil, missingImport(op, name)
+			}
+			ans[name] = val
+		}
+	case "except":
+		names, err := importSetNames(op, parts[2:])
+		if err != nil {
+			return nil, err
+		}
+		for name, val := range inner {
+			ans[name] = val
+		}
+		for _, name := range names {
+			if _, ok := ans[name] ; !ok {
+				return nil, missingImport(op, name)
+			}
+			delete(ans, name)
+		}
+	case "prefix":
+		names, err := importSetNames(op, parts[2:])
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			msg := fmt.Sprintf("Expected 1 prefix, got %d", len(names))
+			return nil, evaluationError{"import", msg}
+		}
+		for name, val := range inner {
+			ans[mkAtomSymbol(names[0].name + name.name)] = val
+		}
+	case "rename":
+		for name, val := range inner {
+			ans[name] = val
+		}
+		for _, pair := range parts[2:] {
+			names, err := importSetNames(op, unconsifyOrNil(pair))
+			if err != nil || len(names) != 2 {
+				msg := fmt.Sprintf("%s is not a renaming (from to)", pair.Sprint())
+				return nil, evaluationError{"import", msg}
+			}
+			val, ok := inner[names[0]]
+			if !ok {
+				return nil, missingImport(op, names[0])
+			}
+			delete(ans, names[0])
+			ans[names[1]] = val
+		}
+	}
+	return ans, nil
+}
+
+// unconsifyOrNil is unconsify for when a non-list is as bad as a list
+// of the wrong length
+func unconsifyOrNil(s sexpr_general) []sexpr_general {
+	items, err := unconsify(s)
+	if err != nil {
+		return nil
+	}
+	return items
+}
+
+// importInto binds each import set in env.  Importing a primitive
+// under its own name is fine; it's already there.
+func (i *Interpreter) importInto(sets []sexpr_general, env *evaluationContext) sexpr_error {
+	for _, set := range sets {
+		imports, err := i.resolveImportSet(set)
+		if err != nil {
+			return err
+		}
+		for name, val := range imports {
+			if old, ok := env.get(name) ; ok && isEq(old, val) {
+				continue
+			}
+			if err := env.bind(name, val) ; err != nil {
+				return evaluationError{"import", err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+// evalImport is (import set ...), outside any library
+func evalImport(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	sets, err := unconsify(lst)
+	if err != nil {
+		return nil, evaluationError{"import", err.Error()}
+	}
+	if err := ctx.interp.importInto(sets, ctx) ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+// evalDefineLibrary is (define-library name declaration ...)
+func evalDefineLibrary(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	args, uerr := unconsify(lst)
+	if uerr != nil {
+		return nil, evaluationError{"define-library", uerr.Error()}
+	}
+	if err := checkArity(len(args), 1, -1) ; err != nil {
+		return nil, evaluationError{"define-library", err.Error()}
+	}
+	interp := ctx.interp
+	key, _, err := libraryName(args[0])
+	if err != nil {
+		return nil, evaluationError{"define-library", err.Error()}
+	}
+	if _, ok := interp.libraries[key] ; ok {
+		msg := fmt.Sprintf("%s is already defined", key)
+		return nil, evaluationError{"define-library", msg}
+	}
+	lib := &library{
+		name: key,
+		env: &evaluationContext{make(symbolTable), nil, interp, nil, nil},
+		exports: make(map[sexpr_atom]sexpr_atom),
+	}
+	for _, declaration := range args[1:] {
+		if err := lib.declare(declaration) ; err != nil {
+			return nil, err
+		}
+	}
+	for external, internal := range lib.exports {
+		if _, ok := lib.env.get(internal) ; !ok {
+			msg := fmt.Sprintf("%s exports %s, which it doesn't define", key, external.Sprint())
+			return nil, evaluationError{"define-library", msg}
+		}
+	}
+	interp.libraries[key] = lib
+	return unspecified, nil
+}
+
+// declare does one of a library's declarations
+func (lib *library) declare(declaration sexpr_general) sexpr_error {
+	parts, err := unconsify(declaration)
+	var head sexpr_atom
+	if err == nil && len(parts) > 0 {
+		head, _ = parts[0].(sexpr_atom)
+	}
+	switch head.name {
+	case "export":
+		for _, spec := range parts[1:] {
+			internal, external, ok := exportSpec(spec)
+			if !ok {
+				msg := fmt.Sprintf("%s is not an export spec", spec.Sprint())
+				return evaluationError{"define-library", msg}
+			}
+			lib.exports[external] = internal
+		}
+		return nil
+	case "import":
+		return lib.env.interp.importInto(parts[1:], lib.env)
+	case "begin":
+		for _, form := range parts[1:] {
+			var err sexpr_error
+			if isDefinition(form, lib.env) {
+				_, err = evalDefinition(form.(sexpr_cons).cdr, lib.env)
+			} else {
+				_, err = evaluateWithContext(form, lib.env)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "include":
+		_, err := evalInclude(declaration.(sexpr_cons).cdr, lib.env)
+		return err
+	}
+	// else
+	known := []string{"export", "import", "begin", "include"}
+	msg := fmt.Sprintf("%s is not a library declaration (expected one of %s)",
+		declaration.Sprint(), strings.Join(known, ", "))
+	return evaluationError{"define-library", msg}
+}
+
+// exportSpec reads name, or (rename internal external)
+func exportSpec(spec sexpr_general) (sexpr_atom, sexpr_atom, bool) {
+	if a, ok := spec.(sexpr_atom) ; ok && a.typ == atomSymbol {
+		return a, a, true
+	}
+	parts := unconsifyOrNil(spec)
+	if len(parts) != 3 || parts[0] != sexpr_general(mkAtomSymbol("rename")) {
+		return sexpr_atom{}, sexpr_atom{}, false
+	}
+	internal, ok1 := parts[1].(sexpr_atom)
+	external, ok2 := parts[2].(sexpr_atom)
+	if !ok1 || !ok2 || internal.typ != atomSymbol || external.typ != atomSymbol {
+		return sexpr_atom{}, sexpr_atom{}, false
+	}
+	return internal, external, true
+}
@@ -0,0 +1,99 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+const statsLibrary = `
+(define-library (stats basic)
+  (export mean (rename sum total) count)
+  (import (scheme base))
+  (begin
+    (define count length)
+    (define sum (lambda (l) (fold-left + 0 l)))
+    (define mean (lambda (l) (/ (sum l) (count l))))))
+`
+
+func TestLibraries(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ statsLibrary, "^$" },
+		{ statsLibrary + "(import (stats basic)) (mean '(1 2 3))", "^2$" },
+		{ statsLibrary + "(import (stats basic)) (total '(1 2 3))", "^6$" },
+		{ statsLibrary + "(import (stats basic)) sum", "sum\\) is not bound" },
+		{ statsLibrary + "(import (only (stats basic) total)) (total '(1 2))", "^3$" },
+		{ statsLibrary + "(import (only (stats basic) total)) mean", "mean\\) is not bound" },
+		{ statsLibrary + "(import (except (stats basic) mean)) mean", "mean\\) is not bound" },
+		{ statsLibrary + "(import (except (stats basic) mean)) (count '(1 2))", "^2$" },
+		{ statsLibrary + "(import (prefix (stats basic) stats:)) (stats:mean '(2 4))", "^3$" },
+		{ statsLibrary + "(import (rename (stats basic) (mean average))) (average '(2 4))", "^3$" },
+		{ statsLibrary + "(import (prefix (only (stats basic) mean) s.)) (s.mean '(1))", "^1$" },
+		{ statsLibrary + "(import (only (stats basic) median))", "median is not in the import set, in only" },
+		{ statsLibrary + "(import (rename (stats basic) (mean car)))", "Cannot redefine primitive car" },
+		{ statsLibrary + statsLibrary, "\\(stats basic\\) is already defined" },
+		// Importing what's already there is harmless
+		{ "(import (scheme base)) (car '(1 2))", "^1$" },
+		{ "(import (only (scheme base) car cdr)) (cdr '(1 2))", `^\(2\)$` },
+		{ "(import (no such library))", "No library named \\(no such library\\)" },
+		{ "(import (stats 1))", "No library named \\(stats 1\\)" },
+		{ "(import (stats \"one\"))", "is not a library name" },
+		// A library sees only what it imports
+		{ `(define-library (bare) (export x) (begin (define x 1)))`, "define\\) is not bound" },
+		{ `(define secret 42)
+		   (define-library (peek) (export peek) (import (scheme base))
+		     (begin (define peek (lambda () secret))))
+		   (import (peek))
+		   (peek)`, "secret\\) is not bound" },
+		{ `(define-library (lib) (import (scheme base)) (export y) (begin (define x 1)))`,
+			"\\(lib\\) exports y, which it doesn't define" },
+		{ `(define-library (lib) (provide x))`, "is not a library declaration" },
+		// Libraries can use each other
+		{ statsLibrary + `
+		   (define-library (stats more)
+		     (export spread)
+		     (import (scheme base) (prefix (stats basic) b:))
+		     (begin
+		       (define spread (lambda (l) (- (b:total l) (b:mean l))))))
+		   (import (stats more))
+		   (spread '(1 2 3))`, "^4$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
+
+func TestLibraryPath(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"libs/stats/basic.sld": statsLibrary,
+		"libs/greet.ss": `
+(define-library (greet)
+  (export greeting)
+  (import (scheme base))
+  (include "greeting.ss"))`,
+		"libs/greeting.ss": `(define greeting "hello")`,
+		"libs/nothing.sld": "(define nothing 0)",
+	})
+
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(import (stats basic)) (mean '(1 2 3))", "^2$" },
+		{ "(import (greet)) greeting", `^"hello"$` },
+		{ "(import (nothing))", "doesn't define the library \\(nothing\\)" },
+		{ "(import (missing))", "No library named \\(missing\\)" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetFileRoot(dir)
+		interp.SetDirectory(dir)
+		interp.SetLibraryPath([]string{"libs"})
+		got := lastResult(interp, test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
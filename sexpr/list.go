@@ -0,0 +1,577 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// The list library: the R7RS list procedures, so that SCAM code
+// needn't keep re-deriving length and member? from car and cdr, and
+// the higher-order ones (map, the folds, and SRFI 1's filter and
+// friends).  Procedure arguments are applied straight from Go.
+
+// mkListFn makes a function of between min and max arguments (max of
+// -1 meaning any number).  Plain errors from fn are attributed to
+// name; sexpr_errors (from applying a procedure argument, say) pass
+// through as they are.
+func mkListFn(name string, min, max int, fn func([]sexpr_general, *evaluationContext) (sexpr_general, error)) applicator {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := checkArity(len(args), min, max) ; err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		ans, err := fn(args, ctx)
+		switch err := err.(type) {
+		case nil:
+			return ans, nil
+		case sexpr_error:
+			return nil, err
+		default:
+			return nil, evaluationError{name, err.Error()}
+		}
+	}
+}
+
+// notAListError is what unconsify says about a list that ends in
+// something other than ()
+func notAListError(s sexpr_general, idx int, list sexpr_general) error {
+	if a, ok := s.(sexpr_atom) ; ok {
+		return fmt.Errorf("Unexpected atom %q in position %d of %s", a, 1+idx, list)
+	}
+	return fmt.Errorf("Unexpected %s in position %d of %s", s.Sprint(), 1+idx, list)
+}
+
+// walkList calls visit on each element of list in turn, until visit
+// says to stop.  It gives the rest of the list from where it stopped
+// (() if it never did).
+func walkList(list sexpr_general, visit func(sexpr_general) (bool, error)) (sexpr_general, error) {
+	for idx, lst := 0, list ; lst != sexpr_general(atomConstantNil) ; idx++ {
+		c, ok := lst.(sexpr_cons)
+		if !ok {
+			return nil, notAListError(lst, idx, list)
+		}
+		if stop, err := visit(c.car) ; err != nil || stop {
+			return lst, err
+		}
+		lst = c.cdr
+	}
+	return atomConstantNil, nil
+}
+
+func fnList(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return ctx.consifyOnto(args, atomConstantNil)
+}
+
+func fnLength(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	n := int64(0)
+	_, err := walkList(args[0], func(sexpr_general) (bool, error) {
+		n += 1
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mkInt(n).sexprize(), nil
+}
+
+// fnAppend copies all but the last of its arguments; the last one
+// becomes the tail of the answer as it is, and needn't be a list
+func fnAppend(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	if len(args) == 0 {
+		return atomConstantNil, nil
+	}
+	var items []sexpr_general
+	for _, arg := range args[:len(args) - 1] {
+		elements, err := unconsify(arg)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, elements...)
+	}
+	return ctx.consifyOnto(items, args[len(args) - 1])
+}
+
+func fnReverse(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	elements, err := unconsify(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.interp.allocate(int64(len(elements))) ; err != nil {
+		return nil, err
+	}
+	ans := sexpr_general(atomConstantNil)
+	for _, elt := range elements {
+		ans = mkCons(elt, ans)
+	}
+	return ans, nil
+}
+
+// listTail is list-tail; list-ref uses it too
+func listTail(list sexpr_general, k sexpr_general) (sexpr_general, error) {
+	n, err := parseIntOrFloat(k)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := n.integer()
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("Index %d is negative", idx)
+	}
+	lst := list
+	for i := int64(0) ; i < idx ; i++ {
+		c, ok := lst.(sexpr_cons)
+		if !ok {
+			return nil, fmt.Errorf("Index %d is out of range for %s", idx, list.Sprint())
+		}
+		lst = c.cdr
+	}
+	return lst, nil
+}
+
+func fnListTail(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return listTail(args[0], args[1])
+}
+
+func fnListRef(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	tail, err := listTail(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	c, ok := tail.(sexpr_cons)
+	if !ok {
+		return nil, fmt.Errorf("Index %s is out of range for %s", args[1].Sprint(), args[0].Sprint())
+	}
+	return c.car, nil
+}
+
+// fnListCopy copies the pairs of a list.  An improper tail is kept as
+// it is, and anything that isn't a pair at all is its own copy.
+func fnListCopy(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	var items []sexpr_general
+	lst := args[0]
+	for {
+		c, ok := lst.(sexpr_cons)
+		if !ok {
+			break
+		}
+		items = append(items, c.car)
+		lst = c.cdr
+	}
+	return ctx.consifyOnto(items, lst)
+}
+
+// mkMember makes memq, memv and member.  member can also take the
+// comparison as a third argument.
+func mkMember(name string, same func(a, b sexpr_general) bool) applicator {
+	max := 2
+	if name == "member" {
+		max = 3
+	}
+	return mkListFn(name, 2, max, func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		compare, err := comparisonArgument(same, args[2:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		tail, err := walkList(args[1], func(elt sexpr_general) (bool, error) {
+			return compare(args[0], elt)
+		})
+		switch {
+		case err != nil:
+			return nil, err
+		case tail == sexpr_general(atomConstantNil):
+			return atomConstantFalse, nil
+		default:
+			return tail, nil
+		}
+	})
+}
+
+// mkAssoc makes assq, assv and assoc, which look through a list of
+// pairs for the first one whose car is the key
+func mkAssoc(name string, same func(a, b sexpr_general) bool) applicator {
+	max := 2
+	if name == "assoc" {
+		max = 3
+	}
+	return mkListFn(name, 2, max, func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		compare, err := comparisonArgument(same, args[2:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		var found sexpr_general = atomConstantFalse
+		_, err = walkList(args[1], func(elt sexpr_general) (bool, error) {
+			c, ok := elt.(sexpr_cons)
+			if !ok {
+				return false, fmt.Errorf("%s is not a pair", elt.Sprint())
+			}
+			match, err := compare(args[0], c.car)
+			if match {
+				found = c
+			}
+			return match, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return found, nil
+	})
+}
+
+// comparisonArgument gives the comparison for member or assoc: the
+// procedure passed as the optional argument, if there is one, or else
+// the default
+func comparisonArgument(
+	same func(a, b sexpr_general) bool,
+	optional []sexpr_general,
+	ctx *evaluationContext,
+) (func(a, b sexpr_general) (bool, error), error) {
+	if len(optional) == 0 {
+		return func(a, b sexpr_general) (bool, error) {
+			return same(a, b), nil
+		}, nil
+	}
+	f, err := procedureArgument(optional[0])
+	if err != nil {
+		return nil, err
+	}
+	return func(a, b sexpr_general) (bool, error) {
+		ans, err := f.apply([]sexpr_general{a, b}, ctx)
+		if err != nil {
+			return false, err
+		}
+		return !isFalsey(ans), nil
+	}, nil
+}
+
+func procedureArgument(s sexpr_general) (func_expr, error) {
+	f, ok := s.(func_expr)
+	if !ok {
+		return f, fmt.Errorf("%s is not a procedure", s.Sprint())
+	}
+	return f, nil
+}
+
+// walkLists is walkList for several lists at once, in step.  visit
+// gets the next element of each, and the walk ends when the shortest
+// list runs out.
+func walkLists(lists []sexpr_general, visit func([]sexpr_general) (bool, error)) error {
+	rest := append([]sexpr_general(nil), lists...)
+	for idx := 0 ; ; idx++ {
+		elts := make([]sexpr_general, len(rest))
+		for j, lst := range rest {
+			if lst == sexpr_general(atomConstantNil) {
+				return nil
+			}
+			c, ok := lst.(sexpr_cons)
+			if !ok {
+				return notAListError(lst, idx, lists[j])
+			}
+			elts[j], rest[j] = c.car, c.cdr
+		}
+		if stop, err := visit(elts) ; err != nil || stop {
+			return err
+		}
+	}
+}
+
+// applyTest applies a predicate, and says whether it held
+func applyTest(f func_expr, args []sexpr_general, ctx *evaluationContext) (sexpr_general, bool, error) {
+	ans, err := f.apply(args, ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return ans, !isFalsey(ans), nil
+}
+
+// mkMapper makes map (which keeps what f gives) and for-each (which
+// doesn't)
+func mkMapper(name string, keep bool) applicator {
+	return mkListFn(name, 2, -1, func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		f, err := procedureArgument(args[0])
+		if err != nil {
+			return nil, err
+		}
+		var results []sexpr_general
+		err = walkLists(args[1:], func(elts []sexpr_general) (bool, error) {
+			ans, err := f.apply(elts, ctx)
+			if keep {
+				results = append(results, ans)
+			}
+			return false, err
+		})
+		switch {
+		case err != nil:
+			return nil, err
+		case keep:
+			return ctx.consifyOnto(results, atomConstantNil)
+		default:
+			return unspecified, nil
+		}
+	})
+}
+
+// sift sorts the elements of a list by a predicate
+func sift(args []sexpr_general, ctx *evaluationContext) (in, out []sexpr_general, err error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = walkList(args[1], func(elt sexpr_general) (bool, error) {
+		_, ok, err := applyTest(f, []sexpr_general{elt}, ctx)
+		if ok {
+			in = append(in, elt)
+		} else {
+			out = append(out, elt)
+		}
+		return false, err
+	})
+	return in, out, err
+}
+
+func fnFilter(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	in, _, err := sift(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.consifyOnto(in, atomConstantNil)
+}
+
+func fnRemove(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	_, out, err := sift(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.consifyOnto(out, atomConstantNil)
+}
+
+// fnPartition gives both lists, as two values
+func fnPartition(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	in, out, err := sift(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	inList, err := ctx.consifyOnto(in, atomConstantNil)
+	if err != nil {
+		return nil, err
+	}
+	outList, err := ctx.consifyOnto(out, atomConstantNil)
+	if err != nil {
+		return nil, err
+	}
+	return mkValues([]sexpr_general{inList, outList}), nil
+}
+
+// fnDelete removes everything equal? to the first argument.  Like
+// member, it can take its own comparison.
+func fnDelete(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	compare, err := comparisonArgument(isEqual, args[2:], ctx)
+	if err != nil {
+		return nil, err
+	}
+	var kept []sexpr_general
+	_, err = walkList(args[1], func(elt sexpr_general) (bool, error) {
+		same, err := compare(args[0], elt)
+		if !same {
+			kept = append(kept, elt)
+		}
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ctx.consifyOnto(kept, atomConstantNil)
+}
+
+// fnFoldLeft is (f (f (f init a1 b1) a2 b2) ...)
+func fnFoldLeft(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	acc := args[1]
+	err = walkLists(args[2:], func(elts []sexpr_general) (bool, error) {
+		acc, err = f.apply(append([]sexpr_general{acc}, elts...), ctx)
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// fnFoldRight is (f a1 b1 (f a2 b2 (... init)))
+func fnFoldRight(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]sexpr_general
+	err = walkLists(args[2:], func(elts []sexpr_general) (bool, error) {
+		rows = append(rows, elts)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	acc := args[1]
+	for idx := len(rows) - 1 ; idx >= 0 ; idx-- {
+		acc, err = f.apply(append(rows[idx], acc), ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// fnReduce is SRFI 1's reduce: fold-left without an initial value
+// (except for the empty list), and with the arguments to f the other
+// way round: (reduce - 0 '(1 2 3)) is (- 3 (- 2 1)).
+func fnReduce(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var acc sexpr_general
+	_, err = walkList(args[2], func(elt sexpr_general) (bool, error) {
+		if acc == nil {
+			acc = elt
+			return false, nil
+		}
+		acc, err = f.apply([]sexpr_general{elt, acc}, ctx)
+		return false, err
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case acc == nil:
+		return args[1], nil
+	default:
+		return acc, nil
+	}
+}
+
+func fnFind(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var found sexpr_general = atomConstantFalse
+	_, err = walkList(args[1], func(elt sexpr_general) (bool, error) {
+		_, ok, err := applyTest(f, []sexpr_general{elt}, ctx)
+		if ok {
+			found = elt
+		}
+		return ok, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// fnAny gives the first true value of the predicate
+func fnAny(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var ans sexpr_general = atomConstantFalse
+	err = walkLists(args[1:], func(elts []sexpr_general) (bool, error) {
+		val, ok, err := applyTest(f, elts, ctx)
+		if ok {
+			ans = val
+		}
+		return ok, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+// fnEvery gives #f, or the last value of the predicate
+func fnEvery(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var ans sexpr_general = atomConstantTrue
+	err = walkLists(args[1:], func(elts []sexpr_general) (bool, error) {
+		val, ok, err := applyTest(f, elts, ctx)
+		ans = val
+		return !ok, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+// mkCxr makes one of caar, cadr, ... cddddr.  The path is the a's
+// and d's from the name, which apply right to left: cadr is the car
+// of the cdr.
+func mkCxr(name string) applicator {
+	path := name[1:len(name) - 1]
+	return mkNaryFn(name, 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		ans := args[0]
+		for idx := len(path) - 1 ; idx >= 0 ; idx-- {
+			c, ok := ans.(sexpr_cons)
+			if !ok {
+				msg := fmt.Sprintf("%s is not a pair", ans.Sprint())
+				return nil, evaluationError{name, msg}
+			}
+			if path[idx] == 'a' {
+				ans = c.car
+			} else {
+				ans = c.cdr
+			}
+		}
+		return ans, nil
+	})
+}
+
+var listFunctions = func() map[string]applicator {
+	table := map[string]applicator {
+		"list":       mkListFn("list", 0, -1, fnList),
+		"length":     mkListFn("length", 1, 1, fnLength),
+		"append":     mkListFn("append", 0, -1, fnAppend),
+		"reverse":    mkListFn("reverse", 1, 1, fnReverse),
+		"list-tail":  mkListFn("list-tail", 2, 2, fnListTail),
+		"list-ref":   mkListFn("list-ref", 2, 2, fnListRef),
+		"list-copy":  mkListFn("list-copy", 1, 1, fnListCopy),
+		"memq":       mkMember("memq", isEq),
+		"memv":       mkMember("memv", isEqv),
+		"member":     mkMember("member", isEqual),
+		"assq":       mkAssoc("assq", isEq),
+		"assv":       mkAssoc("assv", isEqv),
+		"assoc":      mkAssoc("assoc", isEqual),
+		"map":        mkMapper("map", true),
+		"for-each":   mkMapper("for-each", false),
+		"filter":     mkListFn("filter", 2, 2, fnFilter),
+		"remove":     mkListFn("remove", 2, 2, fnRemove),
+		"partition":  mkListFn("partition", 2, 2, fnPartition),
+		"delete":     mkListFn("delete", 2, 3, fnDelete),
+		"fold-left":  mkListFn("fold-left", 3, -1, fnFoldLeft),
+		"fold-right": mkListFn("fold-right", 3, -1, fnFoldRight),
+		"reduce":     mkListFn("reduce", 3, 3, fnReduce),
+		"find":       mkListFn("find", 2, 2, fnFind),
+		"any":        mkListFn("any", 2, -1, fnAny),
+		"every":      mkListFn("every", 2, -1, fnEvery),
+	}
+	// caar through cddddr: every path of two to four a's and d's
+	paths := []string{""}
+	for depth := 1 ; depth <= 4 ; depth++ {
+		var longer []string
+		for _, p := range paths {
+			longer = append(longer, p + "a", p + "d")
+		}
+		paths = longer
+		if depth >= 2 {
+			for _, p := range paths {
+				name := "c" + p + "r"
+				table[name] = mkCxr(name)
+			}
+		}
+	}
+	return table
+}()
@@ -0,0 +1,119 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestListLibrary(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ "(list)", `^\(\)$` },
+		{ "(list 1 (+ 1 1) 'c)", `^\(1 2 c\)$` },
+		{ "(length '(a b c))", "^3$" },
+		{ "(length '())", "^0$" },
+		{ "(length (cons 1 2))", "Exception in length: Unexpected atom .* in position 2 of" },
+		{ "(length (cons 1 car))", "Exception in length: Unexpected fn:car in position 2 of" },
+		{ "(length 'a)", "Exception in length: Unexpected atom .* in position 1" },
+		{ "(append)", `^\(\)$` },
+		{ "(append '(a) '(b c) '() '(d))", `^\(a b c d\)$` },
+		{ "(append '(a) 'b)", `^\(a \. b\)$` },
+		{ "(append 'a)", "^a$" },
+		{ "(append 'a '(b))", "Exception in append: Unexpected atom" },
+		{ "(reverse '(a (b c) d))", `^\(d \(b c\) a\)$` },
+		{ "(reverse '())", `^\(\)$` },
+		{ "(list-tail '(a b c d) 2)", `^\(c d\)$` },
+		{ "(list-tail '(a b) 3)", "Exception in list-tail: Index 3 is out of range for \\(a b\\)" },
+		{ "(list-ref '(a b c) 1)", "^b$" },
+		{ "(list-ref '(a b c) 3)", "Exception in list-ref: Index 3 is out of range" },
+		{ "(list-ref '(a b c) -1)", "Exception in list-ref: Index -1 is negative" },
+		{ "(list-ref '(a b c) 1.5)", "Exception in list-ref: 1.500000 is not an exact integer" },
+		{ "(define l '(1 2)) (eq? l (list-copy l))", "^#f$" },
+		{ "(define l '(1 2)) (equal? l (list-copy l))", "^#t$" },
+		{ "(list-copy 'a)", "^a$" },
+		{ "(memq 'c '(a b c d))", `^\(c d\)$` },
+		{ "(memq 'e '(a b c d))", "^#f$" },
+		{ "(memq '(a) '(b (a) c))", "^#f$" },
+		{ "(member '(a) '(b (a) c))", `^\(\(a\) c\)$` },
+		{ "(memv 1.0 '(1 1.0 2))", `^\(1.0 2\)$` },
+		{ "(member 2.0 '(1 2 3) =)", `^\(2 3\)$` },
+		{ "(member 1 '(1) 'oops)", "Exception in member: oops is not a procedure" },
+		{ "(memq 'a 'b)", "Exception in memq: Unexpected atom" },
+		{ "(memq 'a '(b c) eq?)", "Exception in memq: Expected 2 arguments, got 3" },
+		{ "(assq 'b '((a 1) (b 2)))", `^\(b 2\)$` },
+		{ "(assq 'c '((a 1) (b 2)))", "^#f$" },
+		{ "(assv 5 '((2 3) (5 7) (11 13)))", `^\(5 7\)$` },
+		{ "(assoc 2.0 '((1 1) (2 4) (3 9)) =)", `^\(2 4\)$` },
+		{ "(assoc '(a) '(((a)) ((b))))", `^\(\(a\)\)$` },
+		{ "(assq 'a '(b))", "Exception in assq: b is not a pair" },
+		{ "(cadr '(1 2 3))", "^2$" },
+		{ "(cddr '(1 2 3))", `^\(3\)$` },
+		{ "(caar '((1) 2))", "^1$" },
+		{ "(caddr '(1 2 3))", "^3$" },
+		{ "(cadddr '(1 2 3 4))", "^4$" },
+		{ "(cddddr '(1 2 3 4 5))", `^\(5\)$` },
+		{ "(caddr '(1 2))", "Exception in caddr: \\(\\) is not a pair" },
+	}
+
+	for _, test := range tests {
+		got := lastResult(NewInterpreter(), test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
+
+func TestListLibraryAllocates(t *testing.T) {
+	interp := NewInterpreter()
+	interp.SetLimits(Limits{MaxConses: 3})
+	got := lastResult(interp, "(append '(1 2) '(3 4) '(5 6))")
+	if ok, _ := regexp.MatchString("Exceeded the limit of 3 cons cells", got.Sprint()) ; !ok {
+		t.Errorf("append made %s, despite the limit", got.Sprint())
+	}
+}
+
+func TestHigherOrderProcedures(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ "(map car '((a 1) (b 2)))", `^\(a b\)$` },
+		{ "(map + '(1 2 3) '(10 20 30))", `^\(11 22 33\)$` },
+		{ "(map + '(1 2 3) '(10 20))", `^\(11 22\)$` },
+		{ "(map (lambda (x) (* x x)) '())", `^\(\)$` },
+		{ "(map 'car '((a)))", "Exception in map: car is not a procedure" },
+		{ "(map car '(a))", "Exception in car: .* is not a pair" },
+		{ "(map car 'a)", "Exception in map: Unexpected atom" },
+		{ "(map car)", "Exception in map: Expected at least 2 arguments, got 1" },
+		{ "(for-each car '((a)))", "^$" },
+		{ "(filter odd? '(1 2 3 4 5))", `^\(1 3 5\)$` },
+		{ "(remove odd? '(1 2 3 4 5))", `^\(2 4\)$` },
+		{ "(partition symbol? '(one 2 3 four))", "^\\(one four\\)\n\\(2 3\\)$" },
+		{ "(delete 'a '(a b a c))", `^\(b c\)$` },
+		{ "(delete '(a) '((a) b))", `^\(b\)$` },
+		{ "(delete 3 '(1 5 2 7) <)", `^\(1 2\)$` },
+		{ "(fold-left cons '() '(1 2 3))", `^\(\(\(\(\) \. 1\) \. 2\) \. 3\)$` },
+		{ "(fold-left + 0 '(1 2) '(10 20))", "^33$" },
+		{ "(fold-right cons '() '(1 2 3))", `^\(1 2 3\)$` },
+		{ "(fold-right list 'z '(1 2) '(a b))", `^\(1 a \(2 b z\)\)$` },
+		{ "(reduce + 0 '(1 2 3))", "^6$" },
+		{ "(reduce - 0 '(1 2 3))", "^2$" },
+		{ "(reduce + 0 '())", "^0$" },
+		{ "(find even? '(1 3 4 5))", "^4$" },
+		{ "(find even? '(1 3))", "^#f$" },
+		{ "(any (lambda (x) (cond ((even? x) x) (else #f))) '(1 4 5))", "^4$" },
+		{ "(any < '(3 1) '(2 2))", "^#t$" },
+		{ "(any even? '())", "^#f$" },
+		{ "(every odd? '(1 3))", "^#t$" },
+		{ "(every odd? '(1 2 3))", "^#f$" },
+		{ "(every + '(1 2) '(3 4))", "^6$" },
+		{ "(every odd? '())", "^#t$" },
+		// Procedures made by the different backends all work
+		{ "(define sq (lambda (x) (* x x))) (map sq '(1 2 3))", `^\(1 4 9\)$` },
+		{ "(call/cc (lambda (k) (for-each (lambda (x) (cond ((< x 0) (k x)))) '(1 -2 3))))", "^-2$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
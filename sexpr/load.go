@@ -0,0 +1,203 @@
+package sexpr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reading SCAM source from files.  "load" evaluates a file's forms in
+// the root context; "include" evaluates them where the include is (at
+// top level, or a library's), as if they'd been written there.  Either
+// way, a relative path is relative to the file doing the loading, or
+// (when there isn't one) to the Interpreter's directory.
+
+// resolvePath turns name into a clean absolute path, with any symbolic
+// links followed, and checks that it's under the file root
+func (i *Interpreter) resolvePath(name string) (string, error) {
+	if i == nil || i.fileRoot == "" {
+		return "", fmt.Errorf("Cannot open %q: file access is disabled", name)
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		dir := i.directory
+		if len(i.loading) > 0 {
+			dir = filepath.Dir(i.loading[len(i.loading) - 1])
+		}
+		path = filepath.Join(dir, path)
+	}
+	path, err := realPath(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := realPath(i.fileRoot)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".." + string(filepath.Separator)) {
+		return "", fmt.Errorf("Cannot open %q: it isn't under %s", name, root)
+	}
+	return path, nil
+}
+
+// realPath is filepath.Abs, plus following symbolic links.  A file
+// that isn't there (yet: it might be about to be written) has the
+// links in the directories above it followed.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(path) ; err == nil {
+		return real, nil
+	}
+	if _, err := os.Lstat(path) ; err == nil {
+		// It's there, but it's a link to nowhere.  Writing it would
+		// make a file wherever that is.
+		return "", fmt.Errorf("%s is a broken link", path)
+	}
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path, nil
+	}
+	dir, err = realPath(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// readSource reads and parses the file called name, all of it, so a
+// parse error stops the file before any of it runs.  A file that's
+// already being loaded can't be loaded again inside itself.
+func (i *Interpreter) readSource(name string) (string, []sexpr_general, error) {
+	path, err := i.resolvePath(name)
+	if err != nil {
+		return "", nil, err
+	}
+	for idx, loading := range i.loading {
+		if loading == path {
+			chain := append(append([]string(nil), i.loading[idx:]...), path)
+			return "", nil, fmt.Errorf("Cycle of loads: %s", strings.Join(chain, " -> "))
+		}
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	p, sexprs := Parse(path, mkRuneChannel(string(src)))
+	var forms []sexpr_general
+	for sx := range sexprs {
+		forms = append(forms, sx)
+	}
+	if err := p.Err() ; err != nil {
+		return "", nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return path, forms, nil
+}
+
+// within runs f with path on top of the stack of files being loaded
+func (i *Interpreter) within(path string, f func() sexpr_error) sexpr_error {
+	i.loading = append(i.loading, path)
+	i.nested += 1
+	defer func() {
+		i.loading = i.loading[:len(i.loading) - 1]
+		i.nested -= 1
+	}()
+	return f()
+}
+
+func fileNameArgument(fn string, arg sexpr_general) (string, sexpr_error) {
+	if s, ok := arg.(sexpr_atom) ; ok && s.typ == atomString {
+		return s.name, nil
+	}
+	msg := fmt.Sprintf("%s is not a file name", arg.Sprint())
+	return "", evaluationError{fn, msg}
+}
+
+func fnLoad(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if err := checkArity(len(args), 1, 1) ; err != nil {
+		return nil, evaluationError{"load", err.Error()}
+	}
+	name, err := fileNameArgument("load", args[0])
+	if err != nil {
+		return nil, err
+	}
+	interp := ctx.interp
+	path, forms, rerr := interp.readSource(name)
+	if rerr != nil {
+		return nil, evaluationError{"load", rerr.Error()}
+	}
+	err = interp.within(path, func() sexpr_error {
+		for _, form := range forms {
+			if _, err := interp.evaluateTopLevel(form) ; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+// evalInclude is (include file ...), which gives the value of the last
+// form included.  Like define-record-type, it's only for the top
+// level: the compiled backends settle a body's variables before
+// running it, so they'd never see what an include there defined.  And
+// a body runs whenever it's called, long after the file it came from
+// is done loading, so its paths would be relative to the wrong file.
+func evalInclude(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if ctx.parent != nil {
+		return nil, evaluationError{"include", "Files can only be included at top level"}
+	}
+	args, uerr := unconsify(lst)
+	if uerr != nil {
+		return nil, evaluationError{"include", uerr.Error()}
+	}
+	if err := checkArity(len(args), 1, -1) ; err != nil {
+		return nil, evaluationError{"include", err.Error()}
+	}
+	interp := ctx.interp
+	var val sexpr_general = unspecified
+	for _, arg := range args {
+		name, err := fileNameArgument("include", arg)
+		if err != nil {
+			return nil, err
+		}
+		path, forms, rerr := interp.readSource(name)
+		if rerr != nil {
+			return nil, evaluationError{"include", rerr.Error()}
+		}
+		err = interp.within(path, func() (err sexpr_error) {
+			for _, form := range forms {
+				switch {
+				case ctx == interp.root:
+					val, err = interp.evaluateTopLevel(form)
+				case isDefinition(form, ctx):
+					// A library's top level
+					val, err = evalDefinition(form.(sexpr_cons).cdr, ctx)
+				default:
+					val, err = evaluateWithContext(form, ctx)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
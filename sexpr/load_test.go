@@ -0,0 +1,98 @@
+package sexpr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// writeFiles makes a directory of SCAM source for the load tests
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755) ; err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644) ; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadAndInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"sq.ss":          "(define sq (lambda (n) (* n n)))",
+		"lib/main.ss":    `(load "helpers.ss") (define quad (lambda (n) (double (double n))))`,
+		"lib/helpers.ss": "(define double (lambda (n) (* 2 n)))",
+		"x-plus-one.ss":  "(+ x 1)",
+		"defs.ss":        "(define x 1)",
+		"lib/uses.ss":    `(include "helpers.ss") (define quad (lambda (n) (double (double n))))`,
+		"a.ss":           `(load "b.ss")`,
+		"b.ss":           `(load "a.ss")`,
+		"broken.ss":      "(define fine 1) (define broken (car)",
+		"failing.ss":     "(define before 1) (car 1) (define after 2)",
+	})
+
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(load "sq.ss") (sq 5)`, "^25$" },
+		{ `(load "sq.ss")`, "^$" },
+		// Relative to the file doing the loading
+		{ `(load "lib/main.ss") (quad 3)`, "^12$" },
+		{ `(include "sq.ss") (sq 4)`, "^16$" },
+		{ `(include "sq.ss" "lib/helpers.ss") (double (sq 3))`, "^18$" },
+		{ `(cond (else (include "defs.ss" "x-plus-one.ss")))`, "^2$" },
+		// Relative to the file with the include in it
+		{ `(load "lib/uses.ss") (quad 3)`, "^12$" },
+		// Only at top level, where it's part of loading its file
+		{ `(let ([x 41]) (include "x-plus-one.ss"))`, "Exception in include: Files can only be included at top level" },
+		{ `(define f (lambda () (include "defs.ss") x)) (f)`, "Files can only be included at top level" },
+		{ `(load "a.ss")`, `Cycle of loads: .*a\.ss -> .*b\.ss -> .*a\.ss` },
+		{ `(load "missing.ss")`, "no such file" },
+		{ `(load "../escape.ss")`, "isn't under" },
+		{ `(load sq.ss)`, "not bound" },
+		{ `(load 'sq.ss)`, "is not a file name" },
+		{ `(include)`, "Expected at least 1 arguments, got 0" },
+		// A file that doesn't parse doesn't run
+		{ `(load "broken.ss")`, `broken\.ss` },
+		{ `(load "broken.ss") fine`, "not bound" },
+		// One that fails stops where it fails
+		{ `(load "failing.ss")`, "car" },
+		{ `(load "failing.ss") before`, "^1$" },
+		{ `(load "failing.ss") after`, "not bound" },
+	}
+
+	for _, backend := range []Backend{BackendTreeWalk, BackendClosure, BackendVM} {
+		for _, test := range tests {
+			interp := NewInterpreter()
+			interp.SetBackend(backend)
+			interp.SetFileRoot(dir)
+			interp.SetDirectory(dir)
+			got := lastResult(interp, test.input)
+			if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+				t.Errorf("Backend %d: Evaluate[%s] = %q, want %q",
+					backend, test.input, got.Sprint(), test.want)
+			}
+			if len(interp.loading) != 0 {
+				t.Errorf("Backend %d: Evaluate[%s] left %v loading",
+					backend, test.input, interp.loading)
+			}
+		}
+	}
+}
+
+func TestLoadNeedsAFileRoot(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"one.ss": "(define one 1)"})
+	interp := NewInterpreter()
+	interp.SetDirectory(dir)
+	got := lastResult(interp, `(load "one.ss")`)
+	if ok, _ := regexp.MatchString("file access is disabled", got.Sprint()) ; !ok {
+		t.Errorf("load with no file root = %q, want it refused", got.Sprint())
+	}
+}
@@ -0,0 +1,760 @@
+package sexpr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// The numeric tower, such as it is: every number is an int64 or a
+// float64, kept in an intOrFloat while we work on it.  Arithmetic on
+// two ints stays exact where it can; anything involving a float is
+// inexact.  There are no rationals or complex numbers, and no
+// bignums: arithmetic on ints that would overflow gives a float.
+type intOrFloat struct{
+	asint   int64
+	asfloat float64
+	isInt   bool
+}
+
+var (
+	zeroIntOrFloat = intOrFloat{0, 0.0, true}
+	oneIntOrFloat  = intOrFloat{1, 1.0, true}
+)
+
+func parseIntOrFloat(s sexpr_general) (*intOrFloat, error) {
+	var numberString string
+	switch s := s.(type) {
+	case sexpr_atom:
+		if s.typ != atomNumber {
+			msg := fmt.Sprintf("Atom %q is not a number", s)
+			return nil, errors.New(msg)
+		} else {
+			numberString = s.name
+		}
+	default:
+		msg := fmt.Sprintf("%q is not a number", s)
+		return nil, errors.New(msg)
+	}
+	// else, numberString is the thing to parse
+	ival, err := strconv.ParseInt(numberString, 10, 64)
+	if err == nil {
+		// It's an integer
+		return &intOrFloat{ival, float64(ival), true}, nil
+	}
+	// else
+	fval, err := strconv.ParseFloat(numberString, 64)
+	if err == nil {
+		// It's a float; the integer part is irrelevant
+		return &intOrFloat{0, fval, false}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (i intOrFloat) String() string {
+	if i.isInt {
+		return fmt.Sprintf("%d", i.asint)
+	} else {
+		return fmt.Sprintf("%f", i.asfloat)
+	}
+}
+func (i intOrFloat) sexprize() sexpr_general {
+	return mkAtomNumber(fmt.Sprintf("%s", i))
+}
+
+// addInt, subtractInt and multiplyInt say whether the answer fit
+func addInt(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+func subtractInt(a, b int64) (int64, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+func multiplyInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	return c, c / b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+}
+
+// add m to n, destructively modifying n.  Like +=
+func (n *intOrFloat) increaseBy(m intOrFloat) {
+	var fits bool
+	n.asint, fits = addInt(n.asint, m.asint)
+	n.asfloat += m.asfloat
+	n.isInt = n.isInt && m.isInt && fits
+}
+// multiply m to n, destructively modifying n.  Like *=
+func (n *intOrFloat) multiplyBy(m intOrFloat) {
+	var fits bool
+	n.asint, fits = multiplyInt(n.asint, m.asint)
+	n.asfloat *= m.asfloat
+	n.isInt = n.isInt && m.isInt && fits
+}
+// subtract m from n, destructively modifying n.  Like -=
+func (n *intOrFloat) decreaseBy(m intOrFloat) {
+	var fits bool
+	n.asint, fits = subtractInt(n.asint, m.asint)
+	n.asfloat -= m.asfloat
+	n.isInt = n.isInt && m.isInt && fits
+}
+// divide m into n, destructively modifying n.  Like /=
+func (n *intOrFloat) divideBy(m intOrFloat) error {
+	if (m.isInt && m.asint == 0) || m.asfloat == 0 {
+		return errors.New("Divide by zero")
+	}
+	if n.isInt && m.isInt && n.asint % m.asint == 0 &&
+		!(n.asint == math.MinInt64 && m.asint == -1) {
+		// Integer division is still possible
+		n.asint /= m.asint
+	} else {
+		n.isInt = false
+		n.asfloat /= m.asfloat
+	}
+	return nil
+}
+
+// raise n to the power m, destructively modifying n.  An exact
+// integer to a non-negative exact power stays exact, if it fits;
+// anything else is math.Pow's float.
+func (n *intOrFloat) toPower(m intOrFloat) {
+	if n.isInt && m.isInt && m.asint >= 0 {
+		if ans, fits := powerInt(n.asint, m.asint) ; fits {
+			*n = mkInt(ans)
+			return
+		}
+	}
+	*n = mkFloat(math.Pow(n.asfloat, m.asfloat))
+}
+
+// powerInt raises base to the power exp by repeated squaring, so that
+// a huge exponent takes no more than a few dozen multiplications
+func powerInt(base, exp int64) (int64, bool) {
+	ans := int64(1)
+	fits := true
+	for exp > 0 {
+		if exp & 1 == 1 {
+			if ans, fits = multiplyInt(ans, base) ; !fits {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, fits = multiplyInt(base, base) ; !fits {
+				return 0, false
+			}
+		}
+	}
+	return ans, true
+}
+
+type arithmeticReducerFunction func(*intOrFloat, intOrFloat)
+
+func mkArithmeticReduce(
+	name string,
+	starter intOrFloat,
+	reducer arithmeticReducerFunction,
+) applicator {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		acc := starter // makes a copy!
+		for _, val := range args {
+			iorf, err := parseIntOrFloat(val)
+			if err != nil {
+				return nil, evaluationError{name, err.Error()}
+			}
+			// else, destructively modify the accumulator
+			reducer(&acc, *iorf)
+		}
+		return acc.sexprize(), nil
+	}
+}
+
+func reducePlus(acc *intOrFloat, addend intOrFloat) {
+	acc.increaseBy(addend)
+}
+func reduceTimes(acc *intOrFloat, multiplicand intOrFloat) {
+	acc.multiplyBy(multiplicand)
+}
+
+func fnExponent(nums []intOrFloat) (sexpr_general, error) {
+	ans := nums[0]
+	ans.toPower(nums[1])
+	return ans.sexprize(), nil
+}
+
+func mkInt(i int64) intOrFloat { return intOrFloat{i, float64(i), true} }
+func mkFloat(f float64) intOrFloat { return intOrFloat{0, f, false} }
+
+// compare gives -1, 0 or 1, like strings.Compare
+func (n intOrFloat) compare(m intOrFloat) int {
+	switch {
+	case n.isInt && m.isInt && n.asint < m.asint: return -1
+	case n.isInt && m.isInt && n.asint > m.asint: return 1
+	case n.isInt && m.isInt: return 0
+	case n.asfloat < m.asfloat: return -1
+	case n.asfloat > m.asfloat: return 1
+	default: return 0
+	}
+}
+
+// integer gives the value of n, so long as it's an exact integer
+func (n intOrFloat) integer() (int64, error) {
+	if !n.isInt {
+		return 0, fmt.Errorf("%s is not an exact integer", n)
+	}
+	return n.asint, nil
+}
+
+// parseNumbers parses every one of args, or complains about the
+// first one that isn't a number
+func parseNumbers(args []sexpr_general) ([]intOrFloat, error) {
+	nums := make([]intOrFloat, len(args))
+	for idx, arg := range args {
+		n, err := parseIntOrFloat(arg)
+		if err != nil {
+			return nil, err
+		}
+		nums[idx] = *n
+	}
+	return nums, nil
+}
+
+// mkNumericFn makes a function of between min and max numbers (max
+// of -1 means any number of them).  fn gets them already parsed.
+func mkNumericFn(name string, min, max int, fn func([]intOrFloat) (sexpr_general, error)) applicator {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := checkArity(len(args), min, max) ; err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		nums, err := parseNumbers(args)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		ans, err := fn(nums)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		return ans, nil
+	}
+}
+
+// checkArity says what's wrong with passing n arguments to a function
+// that takes between min and max of them (max of -1 meaning no limit)
+func checkArity(n, min, max int) error {
+	switch {
+	case min == max && n != min:
+		return fmt.Errorf("Expected %d arguments, got %d", min, n)
+	case max < 0 && n < min:
+		return fmt.Errorf("Expected at least %d arguments, got %d", min, n)
+	case max >= 0 && (n < min || n > max):
+		return fmt.Errorf("Expected %d to %d arguments, got %d", min, max, n)
+	}
+	return nil
+}
+
+// mkUnaryNumericFn lifts a function of one number
+func mkUnaryNumericFn(name string, fn func(intOrFloat) (intOrFloat, error)) applicator {
+	return mkNumericFn(name, 1, 1, func(nums []intOrFloat) (sexpr_general, error) {
+		ans, err := fn(nums[0])
+		if err != nil {
+			return nil, err
+		}
+		return ans.sexprize(), nil
+	})
+}
+
+// mkIntegerFn lifts a function of two exact integers, like quotient
+func mkIntegerFn(name string, fn func(int64, int64) (int64, error)) applicator {
+	return mkNumericFn(name, 2, 2, func(nums []intOrFloat) (sexpr_general, error) {
+		n, err := nums[0].integer()
+		if err != nil {
+			return nil, err
+		}
+		m, err := nums[1].integer()
+		if err != nil {
+			return nil, err
+		}
+		ans, err := fn(n, m)
+		if err != nil {
+			return nil, err
+		}
+		return mkInt(ans).sexprize(), nil
+	})
+}
+
+// mkDivisionFn makes floor/ and truncate/, which give the quotient
+// and the remainder, as two values
+func mkDivisionFn(name string, quot, rem func(int64, int64) (int64, error)) applicator {
+	return mkNumericFn(name, 2, 2, func(nums []intOrFloat) (sexpr_general, error) {
+		n, err := nums[0].integer()
+		if err != nil {
+			return nil, err
+		}
+		m, err := nums[1].integer()
+		if err != nil {
+			return nil, err
+		}
+		q, err := quot(n, m)
+		if err != nil {
+			return nil, err
+		}
+		r, err := rem(n, m)
+		if err != nil {
+			return nil, err
+		}
+		return mkValues([]sexpr_general{mkInt(q).sexprize(), mkInt(r).sexprize()}), nil
+	})
+}
+
+// mkFloatFn lifts a function from math.  The answer is always
+// inexact.
+func mkFloatFn(name string, fn func(float64) float64) applicator {
+	return mkUnaryNumericFn(name, func(n intOrFloat) (intOrFloat, error) {
+		ans := fn(n.asfloat)
+		if math.IsNaN(ans) {
+			return n, fmt.Errorf("%s is out of the domain", n)
+		}
+		return mkFloat(ans), nil
+	})
+}
+
+// mkRounder lifts a rounding function from math.  Integers are already
+// round, and floats stay inexact: (floor 2.5) is 2.0, not 2.
+func mkRounder(name string, fn func(float64) float64) applicator {
+	return mkUnaryNumericFn(name, func(n intOrFloat) (intOrFloat, error) {
+		if n.isInt {
+			return n, nil
+		}
+		return mkFloat(fn(n.asfloat)), nil
+	})
+}
+
+// mkNumericComparison makes =, < and the rest, which hold when test
+// holds of every adjacent pair of arguments
+func mkNumericComparison(name string, test func(int) bool) applicator {
+	return mkNumericFn(name, 1, -1, func(nums []intOrFloat) (sexpr_general, error) {
+		for idx := 1 ; idx < len(nums) ; idx++ {
+			if !test(nums[idx - 1].compare(nums[idx])) {
+				return atomConstantFalse, nil
+			}
+		}
+		return atomConstantTrue, nil
+	})
+}
+
+func fnMinus(nums []intOrFloat) (sexpr_general, error) {
+	if len(nums) == 1 {
+		// Negation
+		ans := zeroIntOrFloat
+		ans.decreaseBy(nums[0])
+		return ans.sexprize(), nil
+	}
+	ans := nums[0]
+	for _, subtrahend := range nums[1:] {
+		ans.decreaseBy(subtrahend)
+	}
+	return ans.sexprize(), nil
+}
+
+func fnDivide(nums []intOrFloat) (sexpr_general, error) {
+	if len(nums) == 1 {
+		// Reciprocal
+		nums = []intOrFloat{oneIntOrFloat, nums[0]}
+	}
+	ans := nums[0]
+	for _, divisor := range nums[1:] {
+		if err := ans.divideBy(divisor) ; err != nil {
+			return nil, err
+		}
+	}
+	return ans.sexprize(), nil
+}
+
+func fnMinMax(wantSign int) func([]intOrFloat) (sexpr_general, error) {
+	return func(nums []intOrFloat) (sexpr_general, error) {
+		ans, exact := nums[0], nums[0].isInt
+		for _, n := range nums[1:] {
+			exact = exact && n.isInt
+			if n.compare(ans) == wantSign {
+				ans = n
+			}
+		}
+		if !exact {
+			// One inexact argument makes the answer inexact
+			ans = mkFloat(ans.asfloat)
+		}
+		return ans.sexprize(), nil
+	}
+}
+
+func fnAbs(n intOrFloat) (intOrFloat, error) {
+	if n.compare(zeroIntOrFloat) < 0 {
+		ans := zeroIntOrFloat
+		ans.decreaseBy(n)
+		return ans, nil
+	}
+	return n, nil
+}
+
+func gcd(n, m int64) int64 {
+	for m != 0 {
+		n, m = m, n % m
+	}
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func fnGcd(nums []intOrFloat) (sexpr_general, error) {
+	ans := int64(0)
+	for _, n := range nums {
+		i, err := n.integer()
+		if err != nil {
+			return nil, err
+		}
+		ans = gcd(ans, i)
+	}
+	if ans < 0 {
+		// Only the gcd of math.MinInt64 and 0 doesn't fit
+		return nil, errIntegerOverflow
+	}
+	return mkInt(ans).sexprize(), nil
+}
+
+func fnLcm(nums []intOrFloat) (sexpr_general, error) {
+	ans := int64(1)
+	for _, n := range nums {
+		i, err := n.integer()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			return zeroIntOrFloat.sexprize(), nil
+		}
+		var fits bool
+		if ans, fits = multiplyInt(ans / gcd(ans, i), i) ; !fits {
+			return nil, errIntegerOverflow
+		}
+		if ans < 0 {
+			if ans, fits = subtractInt(0, ans) ; !fits {
+				return nil, errIntegerOverflow
+			}
+		}
+	}
+	return mkInt(ans).sexprize(), nil
+}
+
+var errDivideByZero = errors.New("Divide by zero")
+var errIntegerOverflow = errors.New("Integer overflow")
+
+func quotient(n, m int64) (int64, error) {
+	if m == 0 {
+		return 0, errDivideByZero
+	}
+	if n == math.MinInt64 && m == -1 {
+		return 0, errIntegerOverflow
+	}
+	return n / m, nil
+}
+func remainder(n, m int64) (int64, error) {
+	if m == 0 {
+		return 0, errDivideByZero
+	}
+	return n % m, nil
+}
+// modulo is remainder, but with the sign of the divisor
+func modulo(n, m int64) (int64, error) {
+	r, err := remainder(n, m)
+	if err == nil && r != 0 && (r < 0) != (m < 0) {
+		r += m
+	}
+	return r, err
+}
+func floorQuotient(n, m int64) (int64, error) {
+	q, err := quotient(n, m)
+	if err == nil && n % m != 0 && (n < 0) != (m < 0) {
+		q -= 1
+	}
+	return q, err
+}
+
+// isqrt is the largest integer whose square is at most n
+func isqrt(n int64) int64 {
+	if n < 2 {
+		return n
+	}
+	s := int64(math.Sqrt(float64(n)))
+	// Near the top of the range, the float can round up past the
+	// biggest square root there is
+	if s > maxIsqrt {
+		s = maxIsqrt
+	}
+	// Float rounding can be off by one, either way.  Dividing, rather
+	// than squaring, can't overflow.
+	for s > n / s {
+		s -= 1
+	}
+	for s + 1 <= n / (s + 1) {
+		s += 1
+	}
+	return s
+}
+
+// maxIsqrt is the square root of math.MaxInt64, rounded down
+const maxIsqrt = 3037000499
+
+func fnSqrt(n intOrFloat) (intOrFloat, error) {
+	if n.compare(zeroIntOrFloat) < 0 {
+		return n, fmt.Errorf("%s is negative, and there are no complex numbers", n)
+	}
+	if n.isInt {
+		if s := isqrt(n.asint) ; s * s == n.asint {
+			// A perfect square stays exact
+			return mkInt(s), nil
+		}
+	}
+	return mkFloat(math.Sqrt(n.asfloat)), nil
+}
+
+// fnExactIntegerSqrt gives s and the rest, n - s*s, as two values
+func fnExactIntegerSqrt(nums []intOrFloat) (sexpr_general, error) {
+	n, err := nums[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("%d is negative", n)
+	}
+	s := isqrt(n)
+	return mkValues([]sexpr_general{mkInt(s).sexprize(), mkInt(n - s*s).sexprize()}), nil
+}
+
+func fnLog(nums []intOrFloat) (sexpr_general, error) {
+	ans := math.Log(nums[0].asfloat)
+	if len(nums) == 2 {
+		ans /= math.Log(nums[1].asfloat)
+	}
+	if math.IsNaN(ans) {
+		return nil, fmt.Errorf("%s is out of the domain", nums[0])
+	}
+	return mkFloat(ans).sexprize(), nil
+}
+
+func fnAtan(nums []intOrFloat) (sexpr_general, error) {
+	if len(nums) == 2 {
+		return mkFloat(math.Atan2(nums[0].asfloat, nums[1].asfloat)).sexprize(), nil
+	}
+	return mkFloat(math.Atan(nums[0].asfloat)).sexprize(), nil
+}
+
+func fnExact(n intOrFloat) (intOrFloat, error) {
+	if n.isInt {
+		return n, nil
+	}
+	if n.asfloat != math.Trunc(n.asfloat) || math.IsInf(n.asfloat, 0) ||
+		math.Abs(n.asfloat) > math.MaxInt64 {
+		return n, fmt.Errorf("%s has no exact representation", n)
+	}
+	return mkInt(int64(n.asfloat)), nil
+}
+
+func fnInexact(n intOrFloat) (intOrFloat, error) {
+	return mkFloat(n.asfloat), nil
+}
+
+// radixArgument checks the optional radix of number->string and
+// string->number
+func radixArgument(args []sexpr_general) (int, error) {
+	if len(args) < 2 {
+		return 10, nil
+	}
+	r, err := parseIntOrFloat(args[1])
+	if err != nil {
+		return 0, err
+	}
+	switch radix, _ := r.integer() ; radix {
+	case 2, 8, 10, 16:
+		return int(radix), nil
+	}
+	return 0, fmt.Errorf("%s is not a radix; expected 2, 8, 10 or 16", args[1].Sprint())
+}
+
+func fnNumberToString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if err := checkArity(len(args), 1, 2) ; err != nil {
+		return nil, evaluationError{"number->string", err.Error()}
+	}
+	n, err := parseIntOrFloat(args[0])
+	if err == nil {
+		var radix int
+		radix, err = radixArgument(args)
+		switch {
+		case err != nil:
+		case radix == 10:
+			// Just as it prints
+			return mkAtomString(args[0].Sprint()), nil
+		case n.isInt:
+			return mkAtomString(strconv.FormatInt(n.asint, radix)), nil
+		default:
+			err = fmt.Errorf("Can only write exact integers in radix %d", radix)
+		}
+	}
+	return nil, evaluationError{"number->string", err.Error()}
+}
+
+// fnStringToNumber gives #f, not an error, for a string that isn't a
+// number
+func fnStringToNumber(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if err := checkArity(len(args), 1, 2) ; err != nil {
+		return nil, evaluationError{"string->number", err.Error()}
+	}
+	s, ok := args[0].(sexpr_atom)
+	if !ok || s.typ != atomString {
+		msg := fmt.Sprintf("%s is not a string", args[0].Sprint())
+		return nil, evaluationError{"string->number", msg}
+	}
+	radix, err := radixArgument(args)
+	if err != nil {
+		return nil, evaluationError{"string->number", err.Error()}
+	}
+	if i, err := strconv.ParseInt(s.name, radix, 64) ; err == nil {
+		return mkInt(i).sexprize(), nil
+	}
+	if radix == 10 && decimalSyntax.MatchString(s.name) {
+		if _, err := strconv.ParseFloat(s.name, 64) ; err == nil {
+			return mkAtomNumber(s.name), nil
+		}
+	}
+	return atomConstantFalse, nil
+}
+
+// decimalSyntax is what lexNumber reads as a decimal number.
+// ParseFloat on its own would take "inf", "nan" and hex floats too.
+var decimalSyntax = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// mkNumericPredicate makes a predicate that's only for numbers;
+// (zero? 'a) is an error, not #f
+func mkNumericPredicate(name string, test func(intOrFloat) (bool, error)) applicator {
+	return mkNumericFn(name, 1, 1, func(nums []intOrFloat) (sexpr_general, error) {
+		ans, err := test(nums[0])
+		switch {
+		case err != nil: return nil, err
+		case ans: return atomConstantTrue, nil
+		default: return atomConstantFalse, nil
+		}
+	})
+}
+
+// isIntegral is true of exact integers, and floats like 2.0
+func (n intOrFloat) isIntegral() bool {
+	return n.isInt || (n.asfloat == math.Trunc(n.asfloat) && !math.IsInf(n.asfloat, 0))
+}
+
+// parity is 0 for even numbers and 1 for odd ones
+func parity(n intOrFloat) (int64, error) {
+	switch {
+	case n.isInt:
+		return n.asint & 1, nil
+	case n.isIntegral():
+		return int64(math.Abs(math.Mod(n.asfloat, 2))), nil
+	default:
+		return 0, fmt.Errorf("%s is not an integer", n)
+	}
+}
+
+// isNumber is number?, and is true of every number: there's nothing
+// but reals here
+func isNumber(s sexpr_general) bool {
+	a, ok := s.(sexpr_atom)
+	return ok && a.typ == atomNumber
+}
+
+var numericFunctions = map[string]applicator {
+	"number?":  mkTypePredicate("number?", isNumber),
+	"real?":    mkTypePredicate("real?", isNumber),
+	"rational?": mkTypePredicate("rational?", func(s sexpr_general) bool {
+		n, err := parseIntOrFloat(s)
+		return err == nil && !math.IsInf(n.asfloat, 0) && !math.IsNaN(n.asfloat)
+	}),
+	"integer?": mkTypePredicate("integer?", func(s sexpr_general) bool {
+		n, err := parseIntOrFloat(s)
+		return err == nil && n.isIntegral()
+	}),
+	"exact-integer?": mkTypePredicate("exact-integer?", func(s sexpr_general) bool {
+		n, err := parseIntOrFloat(s)
+		return err == nil && n.isInt
+	}),
+	"exact?":   mkNumericPredicate("exact?", func(n intOrFloat) (bool, error) { return n.isInt, nil }),
+	"inexact?": mkNumericPredicate("inexact?", func(n intOrFloat) (bool, error) { return !n.isInt, nil }),
+	"zero?":     mkNumericPredicate("zero?", func(n intOrFloat) (bool, error) {
+		return n.compare(zeroIntOrFloat) == 0, nil
+	}),
+	"positive?": mkNumericPredicate("positive?", func(n intOrFloat) (bool, error) {
+		return n.compare(zeroIntOrFloat) > 0, nil
+	}),
+	"negative?": mkNumericPredicate("negative?", func(n intOrFloat) (bool, error) {
+		return n.compare(zeroIntOrFloat) < 0, nil
+	}),
+	"odd?":  mkNumericPredicate("odd?", func(n intOrFloat) (bool, error) {
+		p, err := parity(n)
+		return p == 1, err
+	}),
+	"even?": mkNumericPredicate("even?", func(n intOrFloat) (bool, error) {
+		p, err := parity(n)
+		return p == 0, err
+	}),
+	"=":        mkNumericComparison("=", func(c int) bool { return c == 0 }),
+	"<":        mkNumericComparison("<", func(c int) bool { return c < 0 }),
+	">":        mkNumericComparison(">", func(c int) bool { return c > 0 }),
+	"<=":       mkNumericComparison("<=", func(c int) bool { return c <= 0 }),
+	">=":       mkNumericComparison(">=", func(c int) bool { return c >= 0 }),
+	"+":        mkArithmeticReduce("+", zeroIntOrFloat, reducePlus),
+	"*":        mkArithmeticReduce("*", oneIntOrFloat, reduceTimes),
+	"-":        mkNumericFn("-", 1, -1, fnMinus),
+	"/":        mkNumericFn("/", 1, -1, fnDivide),
+	"expt":     mkNumericFn("expt", 2, 2, fnExponent),
+	"abs":      mkUnaryNumericFn("abs", fnAbs),
+	"min":      mkNumericFn("min", 1, -1, fnMinMax(-1)),
+	"max":      mkNumericFn("max", 1, -1, fnMinMax(1)),
+	"gcd":      mkNumericFn("gcd", 0, -1, fnGcd),
+	"lcm":      mkNumericFn("lcm", 0, -1, fnLcm),
+	"quotient":  mkIntegerFn("quotient", quotient),
+	"remainder": mkIntegerFn("remainder", remainder),
+	"modulo":    mkIntegerFn("modulo", modulo),
+	"truncate-quotient":  mkIntegerFn("truncate-quotient", quotient),
+	"truncate-remainder": mkIntegerFn("truncate-remainder", remainder),
+	"floor-quotient":     mkIntegerFn("floor-quotient", floorQuotient),
+	"floor-remainder":    mkIntegerFn("floor-remainder", modulo),
+	"truncate/":          mkDivisionFn("truncate/", quotient, remainder),
+	"floor/":             mkDivisionFn("floor/", floorQuotient, modulo),
+	"floor":    mkRounder("floor", math.Floor),
+	"ceiling":  mkRounder("ceiling", math.Ceil),
+	"round":    mkRounder("round", math.RoundToEven),
+	"truncate": mkRounder("truncate", math.Trunc),
+	"square":   mkNumericFn("square", 1, 1, func(nums []intOrFloat) (sexpr_general, error) {
+		ans := nums[0]
+		ans.multiplyBy(nums[0])
+		return ans.sexprize(), nil
+	}),
+	"sqrt":     mkUnaryNumericFn("sqrt", fnSqrt),
+	"exact-integer-sqrt": mkNumericFn("exact-integer-sqrt", 1, 1, fnExactIntegerSqrt),
+	"exp":      mkFloatFn("exp", math.Exp),
+	"log":      mkNumericFn("log", 1, 2, fnLog),
+	"sin":      mkFloatFn("sin", math.Sin),
+	"cos":      mkFloatFn("cos", math.Cos),
+	"tan":      mkFloatFn("tan", math.Tan),
+	"asin":     mkFloatFn("asin", math.Asin),
+	"acos":     mkFloatFn("acos", math.Acos),
+	"atan":     mkNumericFn("atan", 1, 2, fnAtan),
+	"exact":    mkUnaryNumericFn("exact", fnExact),
+	"inexact":  mkUnaryNumericFn("inexact", fnInexact),
+	"inexact->exact": mkUnaryNumericFn("inexact->exact", fnExact),
+	"exact->inexact": mkUnaryNumericFn("exact->inexact", fnInexact),
+	"number->string": fnNumberToString,
+	"string->number": fnStringToNumber,
+}
@@ -0,0 +1,167 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNumericPrimitives(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ "(- 5)", "^-5$" },
+		{ "(- 10 1 2)", "^7$" },
+		{ "(- 1.5)", "^-1.500000$" },
+		{ "(-)", "Expected at least 1 arguments, got 0" },
+		{ "(/ 2)", "^0.500000$" },
+		{ "(/ 60 2 3)", "^10$" },
+		{ "(/ 1 0)", "Divide by zero" },
+		{ "(< 1 2 3)", "^#t$" },
+		{ "(< 1 3 2)", "^#f$" },
+		{ "(<= 1 1 2)", "^#t$" },
+		{ "(> 3 2.5)", "^#t$" },
+		{ "(>= 2 3)", "^#f$" },
+		{ "(= 1 1.0)", "^#t$" },
+		{ "(= 1 1 2)", "^#f$" },
+		{ "(< 1 'a)", "Exception in <: Atom .*a.* is not a number" },
+		{ "(quotient 17 5)", "^3$" },
+		{ "(quotient -17 5)", "^-3$" },
+		{ "(remainder -17 5)", "^-2$" },
+		{ "(modulo -17 5)", "^3$" },
+		{ "(modulo 17 -5)", "^-3$" },
+		{ "(floor-quotient -17 5)", "^-4$" },
+		{ "(quotient 1 0)", "Exception in quotient: Divide by zero" },
+		{ "(quotient 1.5 1)", "is not an exact integer" },
+		{ "(abs -7)", "^7$" },
+		{ "(abs -7.5)", "^7.500000$" },
+		{ "(min 3 1 2)", "^1$" },
+		{ "(max 1 2.0)", "^2.000000$" },
+		{ "(gcd 32 -36)", "^4$" },
+		{ "(gcd)", "^0$" },
+		{ "(lcm 4 -6)", "^12$" },
+		{ "(lcm)", "^1$" },
+		// Exact answers that don't fit in an int64 come out inexact
+		{ "(* 9223372036854775807 2)", `^18446744073709551616\.0+$` },
+		{ "(* -9223372036854775808 -1)", `^9223372036854775808\.0+$` },
+		{ "(* 3037000500 3037000500)", `^\d+\.0+$` },
+		{ "(+ 9223372036854775807 1)", `^9223372036854775808\.0+$` },
+		{ "(- -9223372036854775808 1)", `^-9223372036854775808\.0+$` },
+		{ "(- -9223372036854775808)", `^9223372036854775808\.0+$` },
+		{ "(abs -9223372036854775808)", `^9223372036854775808\.0+$` },
+		{ "(/ -9223372036854775808 -1)", `^9223372036854775808\.0+$` },
+		{ "(square 4294967296)", `^18446744073709551616\.0+$` },
+		{ "(expt 2 62)", "^4611686018427387904$" },
+		{ "(expt 2 63)", `^9223372036854775808\.0+$` },
+		{ "(expt 2 64)", `^18446744073709551616\.0+$` },
+		{ "(expt -2 63)", "^-9223372036854775808$" },
+		{ "(expt 3 5)", "^243$" },
+		{ "(expt 7 0)", "^1$" },
+		{ "(expt 2 -1)", `^0\.50*$` },
+		{ "(expt 2 -3)", `^0\.1250*$` },
+		{ "(expt 4 -0.5)", `^0\.50*$` },
+		{ "(expt 2.0 3)", `^8\.0+$` },
+		// A huge exponent doesn't take forever
+		{ "(expt 1 9223372036854775807)", "^1$" },
+		{ "(expt -1 9223372036854775807)", "^-1$" },
+		{ "(expt 2 9223372036854775807)", `^\+Inf$` },
+		{ "(expt 0 9223372036854775807)", "^0$" },
+		{ "(+ 9223372036854775807 -1)", "^9223372036854775806$" },
+		{ "(- 9223372036854775807 9223372036854775807)", "^0$" },
+		{ "(quotient -9223372036854775808 -1)", "Exception in quotient: Integer overflow" },
+		{ "(lcm 9223372036854775807 2)", "Exception in lcm: Integer overflow" },
+		{ "(gcd -9223372036854775808)", "Exception in gcd: Integer overflow" },
+		{ "(floor -4.3)", "^-5.000000$" },
+		{ "(ceiling -4.3)", "^-4.000000$" },
+		{ "(truncate -4.3)", "^-4.000000$" },
+		{ "(round 2.5)", "^2.000000$" },
+		{ "(round 3.5)", "^4.000000$" },
+		{ "(round 7)", "^7$" },
+		{ "(sqrt 16)", "^4$" },
+		{ "(sqrt 2)", "^1.414214$" },
+		{ "(sqrt -1)", "Exception in sqrt: -1 is negative" },
+		{ "(exact-integer-sqrt 17)", "^4\n1$" },
+		{ "(exact-integer-sqrt 0)", "^0\n0$" },
+		{ "(exact-integer-sqrt 1)", "^1\n0$" },
+		// At and near the top of the range
+		{ "(exact-integer-sqrt 9223372036854775807)", "^3037000499\n5928526806$" },
+		{ "(exact-integer-sqrt 9223372030926249001)", "^3037000499\n0$" },
+		{ "(exact-integer-sqrt 9223372030926249000)", "^3037000498\n6074000996$" },
+		{ "(sqrt 9223372030926249001)", "^3037000499$" },
+		{ "(floor/ -7 2)", "^-4\n1$" },
+		{ "(truncate/ -7 2)", "^-3\n-1$" },
+		{ "(floor/ 7 0)", "Exception in floor/: " },
+		{ "(square 5)", "^25$" },
+		{ "(exp 0)", "^1.000000$" },
+		{ "(log 8 2)", "^3.000000$" },
+		{ "(log -1)", "out of the domain" },
+		{ "(sin 0)", "^0.000000$" },
+		{ "(atan 1 1)", "^0.785398$" },
+		{ "(exact 2.0)", "^2$" },
+		{ "(exact 2.5)", "has no exact representation" },
+		{ "(inexact 2)", "^2.000000$" },
+		{ "(number->string 255)", `^"255"$` },
+		{ "(number->string 255 16)", `^"ff"$` },
+		{ "(number->string 3.5 2)", "Can only write exact integers in radix 2" },
+		{ "(string->number \"42\")", "^42$" },
+		{ "(string->number \"ff\" 16)", "^255$" },
+		{ "(string->number \"2.5\")", "^2.5$" },
+		{ "(string->number \"abc\")", "^#f$" },
+		{ "(string->number \"-1.5e3\")", "^-1.5e3$" },
+		{ "(string->number \".5\")", `^\.5$` },
+		{ "(string->number \"inf\")", "^#f$" },
+		{ "(string->number \"+Inf\")", "^#f$" },
+		{ "(string->number \"nan\")", "^#f$" },
+		{ "(string->number \"0x1p-2\")", "^#f$" },
+		{ "(string->number \"1e\")", "^#f$" },
+		{ "(string->number 42)", "42 is not a string" },
+		{ "(expt 'a 2)", "Exception in expt" },
+	}
+
+	for _, test := range tests {
+		got := lastResult(NewInterpreter(), test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
+
+func TestNumericPredicates(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the result
+	} {
+		{ "(number? 1.5)", "^#t$" },
+		{ "(number? 'a)", "^#f$" },
+		{ "(integer? 2.0)", "^#t$" },
+		{ "(integer? 2.5)", "^#f$" },
+		{ "(integer? 'a)", "^#f$" },
+		{ "(exact-integer? 2.0)", "^#f$" },
+		{ "(rational? 1.5)", "^#t$" },
+		{ "(real? \"1\")", "^#f$" },
+		{ "(exact? 2)", "^#t$" },
+		{ "(inexact? 2)", "^#f$" },
+		{ "(exact? 'a)", "Exception in exact\\?: .* is not a number" },
+		{ "(zero? 0)", "^#t$" },
+		{ "(zero? 0.0)", "^#t$" },
+		{ "(zero? -0)", "^#t$" },
+		{ "(zero? 'a)", "Exception in zero\\?: .* is not a number" },
+		{ "(positive? 1)", "^#t$" },
+		{ "(positive? 0)", "^#f$" },
+		{ "(negative? -0.5)", "^#t$" },
+		{ "(odd? 7)", "^#t$" },
+		{ "(odd? -7)", "^#t$" },
+		{ "(even? -7)", "^#f$" },
+		{ "(even? 0)", "^#t$" },
+		{ "(even? 4.0)", "^#t$" },
+		{ "(odd? 1.5)", "Exception in odd\\?: 1.500000 is not an integer" },
+		{ "(even? '())", "Exception in even\\?" },
+	}
+
+	for _, test := range tests {
+		got := lastResult(NewInterpreter(), test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
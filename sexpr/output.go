@@ -0,0 +1,149 @@
+package sexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Output.  write prints things the way the REPL does, so that (most
+// of) them could be read back in; display prints strings and
+// characters as themselves, for people to read.  They all go to the
+// current output port, unless they're given another.
+
+// displayString is Sprint, except for strings and characters, even
+// inside lists and vectors
+func displayString(s sexpr_general) string {
+	switch s := s.(type) {
+	case sexpr_atom:
+		switch s.typ {
+		case atomString:
+			return s.name
+		case atomCharacter:
+			return string(s.character())
+		}
+		return s.Sprint()
+	case sexpr_cons:
+		var b strings.Builder
+		b.WriteString("(")
+		for ptr := s ; ; {
+			b.WriteString(displayString(ptr.car))
+			next, ok := ptr.cdr.(sexpr_cons)
+			if !ok {
+				if ptr.cdr != atomConstantNil {
+					b.WriteString(" . " + displayString(ptr.cdr))
+				}
+				break
+			}
+			b.WriteString(" ")
+			ptr = next
+		}
+		b.WriteString(")")
+		return b.String()
+	case sexpr_vector:
+		if s.busy {
+			return "#<cycle>"
+		}
+		s.busy = true
+		defer func() { s.busy = false }()
+		strs := make([]string, len(s.elements))
+		for idx, elt := range s.elements {
+			strs[idx] = displayString(elt)
+		}
+		return "#(" + strings.Join(strs, " ") + ")"
+	}
+	return s.Sprint()
+}
+
+// mkWriter makes the procedures that print their first argument, as
+// shown by show, to the port in the second (if there is one)
+func mkWriter(show func(sexpr_general) (string, error)) func([]sexpr_general, *evaluationContext) (sexpr_general, error) {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		str, err := show(args[0])
+		if err != nil {
+			return nil, err
+		}
+		p, err := outputPortArgument(args, 1, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.write(str, ctx.interp) ; err != nil {
+			return nil, err
+		}
+		return unspecified, nil
+	}
+}
+
+func showWrite(s sexpr_general) (string, error) {
+	return s.Sprint(), nil
+}
+
+func showDisplay(s sexpr_general) (string, error) {
+	return displayString(s), nil
+}
+
+func showCharacter(s sexpr_general) (string, error) {
+	chars, err := parseCharacters([]sexpr_general{s})
+	if err != nil {
+		return "", err
+	}
+	return string(chars[0]), nil
+}
+
+func showString(s sexpr_general) (string, error) {
+	a, ok := s.(sexpr_atom)
+	if !ok || a.typ != atomString {
+		return "", fmt.Errorf("%s is not a string", s.Sprint())
+	}
+	return a.name, nil
+}
+
+// fnWriteString is (write-string string [port [start [end]]]), which
+// writes the characters of string from start to end
+func fnWriteString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	str, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(str)
+	start, end := int64(0), int64(len(runes))
+	if len(args) > 2 {
+		if start, err = indexArgument(args[2]) ; err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 3 {
+		if end, err = indexArgument(args[3]) ; err != nil {
+			return nil, err
+		}
+	}
+	if start > end || end > int64(len(runes)) {
+		return nil, fmt.Errorf("Range %d to %d is out of range for %s", start, end, args[0].Sprint())
+	}
+	p, err := outputPortArgument(args, 1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.write(string(runes[start:end]), ctx.interp) ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+func fnNewline(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := outputPortArgument(args, 0, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.write("\n", ctx.interp) ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+var outputFunctions = map[string]applicator {
+	"display":      mkListFn("display", 1, 2, mkWriter(showDisplay)),
+	"write":        mkListFn("write", 1, 2, mkWriter(showWrite)),
+	"write-char":   mkListFn("write-char", 1, 2, mkWriter(showCharacter)),
+	"write-string": mkListFn("write-string", 1, 4, fnWriteString),
+	"newline":      mkListFn("newline", 0, 1, fnNewline),
+}
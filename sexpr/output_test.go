@@ -0,0 +1,68 @@
+package sexpr
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // what's printed
+	} {
+		{ `(display "hello")`, "hello" },
+		{ `(write "hello")`, `"hello"` },
+		{ `(display "a") (newline) (display "b")`, "a\nb" },
+		{ `(display #\a)`, "a" },
+		{ `(write #\a)`, `#\a` },
+		{ `(write-char #\space)`, " " },
+		{ `(write-string "x\ny")`, "x\ny" },
+		{ `(display '("a" #\b c 1.5))`, "(a b c 1.5)" },
+		{ `(write '("a" #\b c 1.5))`, `("a" #\b c 1.5)` },
+		{ `(display (cons "a" "b"))`, "(a . b)" },
+		{ `(display (vector "a" '("b")))`, "#(a (b))" },
+		{ `(for-each (lambda (n) (display n) (display " ")) '(1 2 3))`, "1 2 3 " },
+		{ `(define v (vector "a" 0)) (vector-set! v 1 v) (display v)`, "#(a #<cycle>)" },
+		// Nothing printed if the arguments are wrong
+		{ `(write-char "a")`, "" },
+		{ `(write-string #\a)`, "" },
+	}
+
+	for _, backend := range []Backend{BackendTreeWalk, BackendClosure, BackendVM} {
+		for _, test := range tests {
+			var out bytes.Buffer
+			interp := NewInterpreter()
+			interp.SetBackend(backend)
+			interp.SetOutput(&out)
+			lastResult(interp, test.input)
+			if got := out.String() ; got != test.want {
+				t.Errorf("Backend %d: Evaluate[%s] printed %q, want %q",
+					backend, test.input, got, test.want)
+			}
+		}
+	}
+}
+
+func TestOutputErrors(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(display "a")`, "^$" },
+		{ `(write-char "a")`, `write-char.*"a" is not a character` },
+		{ `(write-string 'a)`, "write-string.*a is not a string" },
+		{ `(newline 1)`, "newline.*1 is not a port" },
+		{ `(newline (current-output-port) 1)`, "newline.*Expected" },
+		{ `(display)`, "display.*Expected" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetOutput(&bytes.Buffer{})
+		got := lastResult(interp, test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
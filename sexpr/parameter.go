@@ -0,0 +1,190 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// dynamic-wind and parameter objects, as in R7RS.
+//
+// Continuations here only ever escape from Go code (see vm.go): they
+// unwind out of it as errors, and nothing can jump back in.  So it's
+// enough for dynamic-wind to run after on the way out however the
+// thunk finishes, and for parameterize to put the old values back the
+// same way; before never needs to run twice.
+
+// A parameter is a procedure of no arguments that gives its current
+// value, which parameterize changes for a while.  Most keep it to
+// themselves; the current ports keep theirs in the Interpreter.
+type parameter struct{
+	get func() sexpr_general
+	set func(sexpr_general)
+	// What parameterize does to a new value before setting it: check
+	// it, or turn it into something else.  nil leaves it alone.
+	convert func(sexpr_general, *evaluationContext) (sexpr_general, sexpr_error)
+}
+
+func (p *parameter) procedure(name string, primitive bool) func_expr {
+	apply := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if len(args) != 0 {
+			msg := fmt.Sprintf("Expected 0 arguments, got %d", len(args))
+			return nil, evaluationError{name, msg}
+		}
+		return p.get(), nil
+	}
+	return func_expr{name, apply, primitive, nil, p, false, nextSerialNumber()}
+}
+
+func parameterArgument(s sexpr_general) (*parameter, error) {
+	if f, ok := s.(func_expr) ; ok && f.param != nil {
+		return f.param, nil
+	}
+	return nil, fmt.Errorf("%s is not a parameter", s.Sprint())
+}
+
+// parameterize calls body with the parameters set to vals, which have
+// already been converted, and then puts them back
+func parameterize(params []*parameter, vals []sexpr_general, body func() (sexpr_general, sexpr_error)) (sexpr_general, sexpr_error) {
+	saved := make([]sexpr_general, len(params))
+	for idx, p := range params {
+		saved[idx] = p.get()
+	}
+	defer func() {
+		for idx := len(params) - 1 ; idx >= 0 ; idx-- {
+			params[idx].set(saved[idx])
+		}
+	}()
+	for idx, p := range params {
+		p.set(vals[idx])
+	}
+	return body()
+}
+
+// fnMakeParameter is (make-parameter value [converter]).  The
+// converter sees the first value too.
+func fnMakeParameter(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p := &parameter{}
+	if len(args) > 1 {
+		f, err := procedureArgument(args[1])
+		if err != nil {
+			return nil, err
+		}
+		p.convert = func(s sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return f.apply([]sexpr_general{s}, ctx)
+		}
+	}
+	value := args[0]
+	if p.convert != nil {
+		var err sexpr_error
+		if value, err = p.convert(value, ctx) ; err != nil {
+			return nil, err
+		}
+	}
+	p.get = func() sexpr_general { return value }
+	p.set = func(s sexpr_general) { value = s }
+	return p.procedure("parameter", false), nil
+}
+
+// evalParameterize is (parameterize ((param value) ...) body ...).
+// The parameters and values are all evaluated (and converted) first.
+func evalParameterize(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	args, err := unconsifyAtLeastN(lst, 2)
+	if err != nil {
+		return nil, evaluationError{"parameterize", err.Error()}
+	}
+	bindings, err := unconsify(args[0])
+	if err != nil {
+		return nil, evaluationError{"parameterize", err.Error()}
+	}
+	params := make([]*parameter, len(bindings))
+	vals := make([]sexpr_general, len(bindings))
+	for idx, b := range bindings {
+		kv, err := unconsifyN(b, 2)
+		if err != nil {
+			return nil, evaluationError{"parameterize", err.Error()}
+		}
+		f, serr := evaluateWithContext(kv[0], ctx)
+		if serr != nil {
+			return nil, serr
+		}
+		if params[idx], err = parameterArgument(f) ; err != nil {
+			return nil, evaluationError{"parameterize", err.Error()}
+		}
+		if vals[idx], serr = evaluateWithContext(kv[1], ctx) ; serr != nil {
+			return nil, serr
+		}
+		if convert := params[idx].convert ; convert != nil {
+			if vals[idx], serr = convert(vals[idx], ctx) ; serr != nil {
+				return nil, serr
+			}
+		}
+	}
+	return parameterize(params, vals, func() (sexpr_general, sexpr_error) {
+		return evalBody("parameterize", args[1:], ctx.extend())
+	})
+}
+
+// fnDynamicWind is (dynamic-wind before thunk after): after runs once
+// thunk is done, even if it fails or a continuation escapes from it.
+func fnDynamicWind(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	procs := make([]func_expr, len(args))
+	for idx, arg := range args {
+		var err error
+		if procs[idx], err = procedureArgument(arg) ; err != nil {
+			return nil, err
+		}
+	}
+	before, thunk, after := procs[0], procs[1], procs[2]
+	if _, err := before.apply(nil, ctx) ; err != nil {
+		return nil, err
+	}
+	val, err := thunk.apply(nil, ctx)
+	if _, afterErr := after.apply(nil, ctx) ; afterErr != nil {
+		return nil, afterErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// portParameter is the parameter for current-input-port or one of the
+// others, which keep their ports in the Interpreter's fields
+func portParameter(name string, field *sexpr_port, output bool) *parameter {
+	return &parameter{
+		get: func() sexpr_general { return *field },
+		set: func(s sexpr_general) { *field = s.(sexpr_port) },
+		convert: func(s sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			var err error
+			if output {
+				_, err = outputPortArgument([]sexpr_general{s}, 0, ctx)
+			} else {
+				_, err = inputPortArgument([]sexpr_general{s}, 0, ctx)
+			}
+			if err != nil {
+				return nil, evaluationError{name, err.Error()}
+			}
+			return s, nil
+		},
+	}
+}
+
+func (i *Interpreter) outputParameter() *parameter {
+	return portParameter("current-output-port", &i.output, true)
+}
+
+// bindPortParameters binds the current ports' parameters, which
+// (unlike the rest of the primitives) belong to the Interpreter
+func (i *Interpreter) bindPortParameters() {
+	for name, p := range map[string]*parameter{
+		"current-input-port":  portParameter("current-input-port", &i.input, false),
+		"current-output-port": i.outputParameter(),
+		"current-error-port":  portParameter("current-error-port", &i.errorOutput, true),
+	} {
+		i.root.bind(mkAtomSymbol(name), p.procedure(name, true))
+	}
+}
+
+var parameterFunctions = map[string]applicator {
+	"make-parameter": mkListFn("make-parameter", 1, 2, fnMakeParameter),
+	"dynamic-wind":   mkListFn("dynamic-wind", 3, 3, fnDynamicWind),
+}
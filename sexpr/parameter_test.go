@@ -0,0 +1,75 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+const windTrail = `
+(define trail (open-output-string))
+(define note (lambda (x) (display x trail)))
+`
+
+func TestParameters(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(define p (make-parameter 10)) (p)", "^10$" },
+		{ "(define p (make-parameter 10)) (list (parameterize ((p 20)) (p)) (p))", `^\(20 10\)$` },
+		{ "(define p (make-parameter 10 (lambda (x) (* x 2)))) (list (p) (parameterize ((p 3)) (p)))", `^\(20 6\)$` },
+		{ "(define p (make-parameter 1)) (define f (lambda () (p))) (parameterize ((p 2)) (f))", "^2$" },
+		{ `(define p (make-parameter 1)) (define q (make-parameter 2))
+		   (parameterize ((p (q)) (q (p))) (list (p) (q)))`, `^\(2 1\)$` },
+		{ "(define p (make-parameter 1)) (p 2)", "Exception in parameter: Expected 0 arguments, got 1" },
+		{ "(parameterize ((car 1)) 1)", "Exception in parameterize: fn:car is not a parameter" },
+		{ "(make-parameter 1 2)", "Exception in make-parameter: 2 is not a procedure" },
+		// The old value comes back however the body finishes
+		{ "(define p (make-parameter 1)) (call/cc (lambda (k) (parameterize ((p 2)) (k 0)))) (p)", "^1$" },
+		{ "(define p (make-parameter 1)) (parameterize ((p 2)) (car '())) (p)", "^1$" },
+		// The current ports are parameters
+		{ `(define s (open-output-string))
+		   (parameterize ((current-output-port s)) (display "hi") (write 'there))
+		   (get-output-string s)`, `^"hithere"$` },
+		{ `(with-output-to-string (lambda ()
+		     (display "a")
+		     (parameterize ((current-output-port (open-output-string))) (display "b"))
+		     (display "c")))`, `^"ac"$` },
+		{ `(define s (open-input-string "x"))
+		   (parameterize ((current-input-port s)) (read-char))`, `^#\\x$` },
+		{ "(parameterize ((current-output-port 1)) 1)", "Exception in current-output-port: 1 is not a port" },
+		{ `(parameterize ((current-error-port (open-input-string ""))) 1)`, "is not an output port" },
+		{ "(primitive? current-output-port)", "^#t$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
+
+func TestDynamicWind(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(dynamic-wind (lambda () (note "before ")) (lambda () (note "during ") 'done) (lambda () (note "after")))`, "^done$" },
+		{ `(dynamic-wind (lambda () (note "before ")) (lambda () (note "during ") 'done) (lambda () (note "after")))
+		   (get-output-string trail)`, `^"before during after"$` },
+		// after runs when a continuation escapes, or there's an error
+		{ `(call/cc (lambda (k) (dynamic-wind (lambda () (note "in ")) (lambda () (k 'escaped)) (lambda () (note "out")))))`, "^escaped$" },
+		{ `(call/cc (lambda (k) (dynamic-wind (lambda () (note "in ")) (lambda () (k 'escaped)) (lambda () (note "out")))))
+		   (get-output-string trail)`, `^"in out"$` },
+		{ `(dynamic-wind (lambda () (note "in ")) (lambda () (car '())) (lambda () (note "out")))`, "Exception in car" },
+		{ `(dynamic-wind (lambda () (note "in ")) (lambda () (car '())) (lambda () (note "out")))
+		   (get-output-string trail)`, `^"in out"$` },
+		// Nested, they unwind innermost first
+		{ `(call/cc (lambda (k)
+		     (dynamic-wind
+		       (lambda () (note "1"))
+		       (lambda () (dynamic-wind (lambda () (note "2")) (lambda () (k 0)) (lambda () (note "3"))))
+		       (lambda () (note "4")))))
+		   (get-output-string trail)`, `^"1234"$` },
+		{ "(dynamic-wind 1 2 3)", "Exception in dynamic-wind: 1 is not a procedure" },
+	}
+
+	checkBackends(t, tests, func(interp *Interpreter) {
+		lastResult(interp, windTrail)
+	})
+}
@@ -21,6 +21,7 @@ var (
 	emptyStackError error = errors.New("Pop from an empty stack")
 	markerLPAREN sexpr_parse_artifact = sexpr_parse_artifact{"LPAREN"}
 	markerQUOTE  sexpr_parse_artifact = sexpr_parse_artifact{"QUOTE"}
+	markerVECTOR sexpr_parse_artifact = sexpr_parse_artifact{"VECTOR"}
 )
 
 type stackOfSexprs struct {
@@ -43,8 +44,17 @@ type parser struct {
 	sexprs chan sexpr_general
 	// State-type things
 	stack *stackOfSexprs
+	// The first parse error, if there was one.  It's safe to look
+	// once the channel of S-expressions is closed.
+	err error
+	// Don't print errors, just keep them (for read)
+	quiet bool
 }
 
+// Err gives the first parse error, once all the S-expressions have
+// been read
+func (p *parser) Err() error { return p.err }
+
 // stack managment tools
 func (p *parser) pushStack(l sexpr_general) {
 	p.stack = &stackOfSexprs{l, p.stack}
@@ -77,8 +87,9 @@ func (p *parser) peekStack() sexpr_general {
 // parse qua parse tools
 
 // popUntil removes elements from HEAD until reaching an object that
-// equals (==) the marker.  It returns an array of S-expressions
-// removed.  That array is reversed with respect to the order popped.
+// equals (==) one of the markers.  It returns an array of S-expressions
+// removed, and the marker it found.  That array is reversed with
+// respect to the order popped.
 // So if the stack is
 //
 //   x -> y -> z -> marker
@@ -88,19 +99,21 @@ func (p *parser) peekStack() sexpr_general {
 //   {z, y, x}
 //
 // If no marker is found, an error is returned.
-func (p *parser) popStackUntil(marker sexpr_general) ([]sexpr_general, error) {
+func (p *parser) popStackUntil(markers ...sexpr_general) ([]sexpr_general, sexpr_general, error) {
 	var acc []sexpr_general
 	for {
 		head, err := p.popStack()
 		if err == emptyStackError {
-			return nil,  errors.New(fmt.Sprintf("popUntil(%s) from a stack with no %q", marker, marker))
+			return nil, nil, errors.New(fmt.Sprintf("popUntil(%s) from a stack with no %q", markers[0], markers[0]))
 		} else if err != nil {
-			return nil, err
-		} else if head == marker {
-			return acc, nil
-		} else {
-			acc = append([]sexpr_general{head}, acc...)
+			return nil, nil, err
+		}
+		for _, marker := range markers {
+			if head == marker {
+				return acc, marker, nil
+			}
 		}
+		acc = append([]sexpr_general{head}, acc...)
 	}
 }
 
@@ -124,6 +137,12 @@ func (p *parser) paniqf(format string, args ...interface{}) {
 	if !strings.HasSuffix(format, "\n") {
 		format += "\n"
 	}
+	if p.err == nil {
+		p.err = fmt.Errorf(strings.TrimSuffix(format, "\n"), args...)
+	}
+	if p.quiet {
+		return
+	}
 	fmt.Printf("PARSE ERROR: " + format, args...)
 	// TODO:  Give some indication of _where_!!
 	fmt.Printf("«TODO:  Better parse-error context»\n")
@@ -134,10 +153,16 @@ func (p *parser) unsupportedf(format string, args ...interface{}) {
 }
 
 func Parse(name string, input <-chan rune) (*parser, <-chan sexpr_general) {
+	return parse(name, input, false)
+}
+
+// parse is Parse, but a quiet parser keeps its errors to itself
+func parse(name string, input <-chan rune, quiet bool) (*parser, <-chan sexpr_general) {
 	p := &parser{
 		name: name,
 		sexprs: make(chan sexpr_general),
 		stack: nil,
+		quiet: quiet,
 	}
 	p.lex, p.items = lex("lexer_"+name, input)
 	go p.run()
@@ -157,15 +182,20 @@ func (p *parser) run() {
 			return
 		case itemLparen:
 			p.pushStack(markerLPAREN)
+		case itemVectorStart:
+			p.pushStack(markerVECTOR)
 		case itemRparen:
-			slist, err := p.popStackUntil(markerLPAREN)
+			slist, marker, err := p.popStackUntil(markerLPAREN, markerVECTOR)
 			if err != nil {
 				p.paniqf(err.Error())
 				return
 			}
 			// else
-			s := consify(slist)
-			p.emit(s)
+			if marker == markerVECTOR {
+				p.emit(mkVector(slist))
+			} else {
+				p.emit(consify(slist))
+			}
 		case itemSingleQuote:
 			p.pushStack(markerQUOTE)
 		case itemNumber:
@@ -179,7 +209,21 @@ func (p *parser) run() {
 			default:
 				p.paniqf("Illegal boolean token %v", tok)
 			}
-		case itemQuotationMark, itemDot:
+		case itemString:
+			str, err := unescapeString(tok.val[1:len(tok.val)-1])
+			if err != nil {
+				p.paniqf(err.Error())
+				return
+			}
+			p.emit(mkAtomString(str))
+		case itemCharacter:
+			r, err := parseCharacter(tok.val[1:])
+			if err != nil {
+				p.paniqf(err.Error())
+				return
+			}
+			p.emit(mkAtomCharacter(r))
+		case itemDot:
 			p.unsupportedf("We aren't ready for '%s' yet", tok)
 			return
 		case itemWhitespace, itemComment:
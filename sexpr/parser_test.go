@@ -63,6 +63,30 @@ func TestSimpleParse(t *testing.T) {
 			"o o+",
 			[]sexpr_general{ mkAtomSymbol("o"), mkAtomSymbol("o+") },
 		},
+		{
+			`"a\tb" "say \"hi\"\n" "\x41;"`,
+			[]sexpr_general{
+				mkAtomString("a\tb"), mkAtomString("say \"hi\"\n"), mkAtomString("A"),
+			},
+		},
+		{
+			`#\a #\λ #\newline #\x3bb #\x`,
+			[]sexpr_general{
+				mkAtomCharacter('a'), mkAtomCharacter('λ'), mkAtomCharacter('\n'),
+				mkAtomCharacter('λ'), mkAtomCharacter('x'),
+			},
+		},
+		{
+			"#(1 (a) #()) '#(b)",
+			[]sexpr_general{
+				mkVector([]sexpr_general{
+					mkAtomNumber("1"),
+					mkList(mkAtomSymbol("a")),
+					mkVector(nil),
+				}),
+				mkList(atomConstantQuote, mkVector([]sexpr_general{mkAtomSymbol("b")})),
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,367 @@
+package sexpr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Ports are where input comes from and output goes: strings, files,
+// or whatever the host hands the Interpreter (see SetInput and
+// SetOutput).  Files can only be opened under the file root, as with
+// load.
+type sexpr_port struct{
+	*port
+}
+
+type port struct{
+	name string
+	in  *bufio.Reader // for input ports
+	out io.Writer     // for output ports
+	// What's been written to a string port, for get-output-string
+	buffer *strings.Builder
+	closer io.Closer // for file ports
+	// Reading never has to wait (as it might on a terminal), so
+	// char-ready? is always true
+	alwaysReady bool
+	closed bool
+	// Where the next character to read is, counting from 0, for
+	// read's error messages
+	line, column int
+	serialNumber int64
+}
+
+func mkInputPort(name string, r io.Reader, alwaysReady bool) sexpr_port {
+	return sexpr_port{&port{
+		name: name,
+		in: bufio.NewReader(r),
+		alwaysReady: alwaysReady,
+		serialNumber: nextSerialNumber(),
+	}}
+}
+
+func mkOutputPort(name string, w io.Writer) sexpr_port {
+	return sexpr_port{&port{name: name, out: w, serialNumber: nextSerialNumber()}}
+}
+
+func mkStringOutputPort() sexpr_port {
+	b := &strings.Builder{}
+	p := mkOutputPort("string", b)
+	p.buffer = b
+	return p
+}
+
+func (p sexpr_port) Sprint() string {
+	kind := "input-port"
+	if p.out != nil {
+		kind = "output-port"
+	}
+	if p.closed {
+		kind = "closed-" + kind
+	}
+	return fmt.Sprintf("#<%s %s>", kind, p.name)
+}
+
+// The end-of-file object is what reading gives at the end of the
+// input.  There's only the one.
+type sexpr_eof struct{}
+
+func (e sexpr_eof) Sprint() string { return "#<eof>" }
+
+var eofObject = sexpr_eof{}
+
+// write writes str.  What a string port holds is memory, so it's
+// charged to the interpreter, like cons cells.
+func (p *port) write(str string, interp *Interpreter) error {
+	if p.closed {
+		return fmt.Errorf("%s is closed", sexpr_port{p}.Sprint())
+	}
+	if p.buffer != nil {
+		if err := interp.allocateText(len(str)) ; err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(p.out, str)
+	return err
+}
+
+// contents is what's been written to a string port, as a string.  The
+// copy is charged too.
+func (p *port) contents(interp *Interpreter) (sexpr_general, error) {
+	if err := interp.allocateText(p.buffer.Len()) ; err != nil {
+		return nil, err
+	}
+	return mkAtomString(p.buffer.String()), nil
+}
+
+// readRune gives the next character, or ok false at the end
+func (p *port) readRune(peek bool) (r rune, ok bool, err error) {
+	if p.closed {
+		return 0, false, fmt.Errorf("%s is closed", sexpr_port{p}.Sprint())
+	}
+	r, _, err = p.in.ReadRune()
+	if err == io.EOF {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	if peek {
+		p.in.UnreadRune()
+	} else {
+		p.advance(r)
+	}
+	return r, true, nil
+}
+
+func (p *port) advance(r rune) {
+	if r == '\n' {
+		p.line, p.column = p.line + 1, 0
+	} else {
+		p.column += 1
+	}
+}
+
+// position says where the next character is, for people
+func (p *port) position() string {
+	return fmt.Sprintf("line %d, column %d of %s", p.line + 1, p.column + 1, p.name)
+}
+
+func (p *port) close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+func portArgument(s sexpr_general) (sexpr_port, error) {
+	p, ok := s.(sexpr_port)
+	if !ok {
+		return p, fmt.Errorf("%s is not a port", s.Sprint())
+	}
+	return p, nil
+}
+
+// inputPortArgument is the port in args[idx], if there's an argument
+// there, or else the current input port
+func inputPortArgument(args []sexpr_general, idx int, ctx *evaluationContext) (sexpr_port, error) {
+	if idx >= len(args) {
+		return ctx.interp.input, nil
+	}
+	p, err := portArgument(args[idx])
+	if err == nil && p.in == nil {
+		err = fmt.Errorf("%s is not an input port", p.Sprint())
+	}
+	return p, err
+}
+
+// outputPortArgument is inputPortArgument for output
+func outputPortArgument(args []sexpr_general, idx int, ctx *evaluationContext) (sexpr_port, error) {
+	if idx >= len(args) {
+		return ctx.interp.output, nil
+	}
+	p, err := portArgument(args[idx])
+	if err == nil && p.out == nil {
+		err = fmt.Errorf("%s is not an output port", p.Sprint())
+	}
+	return p, err
+}
+
+func fnOpenInputString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	str, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkInputPort("string", strings.NewReader(str), true), nil
+}
+
+func fnOpenOutputString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return mkStringOutputPort(), nil
+}
+
+func fnGetOutputString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := portArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if p.buffer == nil {
+		return nil, fmt.Errorf("%s is not a string output port", p.Sprint())
+	}
+	return p.contents(ctx.interp)
+}
+
+// fnCallWithOutputString calls the procedure with a fresh string port,
+// and gives back what it wrote there
+func fnCallWithOutputString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	p := mkStringOutputPort()
+	if _, err := f.apply([]sexpr_general{p}, ctx) ; err != nil {
+		return nil, err
+	}
+	return p.contents(ctx.interp)
+}
+
+// fnWithOutputToString calls the thunk with current-output-port
+// parameterized to a fresh string port, and gives back what it wrote
+func fnWithOutputToString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	f, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	p := mkStringOutputPort()
+	params := []*parameter{ctx.interp.outputParameter()}
+	_, serr := parameterize(params, []sexpr_general{p}, func() (sexpr_general, sexpr_error) {
+		return f.apply(nil, ctx)
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	return p.contents(ctx.interp)
+}
+
+func fnOpenInputFile(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	name, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	path, err := ctx.interp.resolvePath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p := mkInputPort(path, f, true)
+	p.closer = f
+	return p, nil
+}
+
+// fnOpenOutputFile makes the file (or empties it, if it's there)
+func fnOpenOutputFile(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	name, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	path, err := ctx.interp.resolvePath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	p := mkOutputPort(path, f)
+	p.closer = f
+	return p, nil
+}
+
+// mkCharacterReader makes read-char and peek-char
+func mkCharacterReader(peek bool) func([]sexpr_general, *evaluationContext) (sexpr_general, error) {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		p, err := inputPortArgument(args, 0, ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, ok, err := p.readRune(peek)
+		switch {
+		case err != nil:
+			return nil, err
+		case !ok:
+			return eofObject, nil
+		}
+		return mkAtomCharacter(r), nil
+	}
+}
+
+// fnReadLine gives the next line, without its line ending
+func fnReadLine(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := inputPortArgument(args, 0, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.closed {
+		return nil, fmt.Errorf("%s is closed", p.Sprint())
+	}
+	line, err := p.in.ReadString('\n')
+	switch {
+	case err == io.EOF && line == "":
+		return eofObject, nil
+	case err != nil && err != io.EOF:
+		return nil, err
+	}
+	for _, r := range line {
+		p.advance(r)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return mkAtomString(line), nil
+}
+
+func fnCharReady(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := inputPortArgument(args, 0, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.alwaysReady || p.in.Buffered() > 0 {
+		return atomConstantTrue, nil
+	}
+	return atomConstantFalse, nil
+}
+
+func fnClosePort(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := portArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := p.close() ; err != nil {
+		return nil, err
+	}
+	return unspecified, nil
+}
+
+func mkPortPredicate(name string, test func(sexpr_port) bool) applicator {
+	return mkTypePredicate(name, func(s sexpr_general) bool {
+		p, ok := s.(sexpr_port)
+		return ok && test(p)
+	})
+}
+
+// The writing ones (display and so on) are in output.go, and the
+// current ports are parameters (see parameter.go)
+var portFunctions = map[string]applicator {
+	"port?":         mkPortPredicate("port?", func(p sexpr_port) bool { return true }),
+	"input-port?":   mkPortPredicate("input-port?", func(p sexpr_port) bool { return p.in != nil }),
+	"output-port?":  mkPortPredicate("output-port?", func(p sexpr_port) bool { return p.out != nil }),
+	"eof-object":    mkListFn("eof-object", 0, 0, func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		return eofObject, nil
+	}),
+	"eof-object?":   mkTypePredicate("eof-object?", func(s sexpr_general) bool {
+		_, ok := s.(sexpr_eof)
+		return ok
+	}),
+
+	"open-input-string":       mkListFn("open-input-string", 1, 1, fnOpenInputString),
+	"open-output-string":      mkListFn("open-output-string", 0, 0, fnOpenOutputString),
+	"get-output-string":       mkListFn("get-output-string", 1, 1, fnGetOutputString),
+	"call-with-output-string": mkListFn("call-with-output-string", 1, 1, fnCallWithOutputString),
+	"with-output-to-string":   mkListFn("with-output-to-string", 1, 1, fnWithOutputToString),
+	"open-input-file":         mkListFn("open-input-file", 1, 1, fnOpenInputFile),
+	"open-output-file":        mkListFn("open-output-file", 1, 1, fnOpenOutputFile),
+	"close-port":              mkListFn("close-port", 1, 1, fnClosePort),
+	"close-input-port":        mkListFn("close-input-port", 1, 1, fnClosePort),
+	"close-output-port":       mkListFn("close-output-port", 1, 1, fnClosePort),
+
+	"read-char":   mkListFn("read-char", 0, 1, mkCharacterReader(false)),
+	"peek-char":   mkListFn("peek-char", 0, 1, mkCharacterReader(true)),
+	"read-line":   mkListFn("read-line", 0, 1, fnReadLine),
+	"char-ready?": mkListFn("char-ready?", 0, 1, fnCharReady),
+}
@@ -0,0 +1,118 @@
+package sexpr
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPorts(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(define p (open-input-string "ab")) (list (read-char p) (peek-char p) (read-char p))`, `^\(#\\a #\\b #\\b\)$` },
+		{ `(define p (open-input-string "a")) (read-char p) (eof-object? (read-char p))`, "^#t$" },
+		{ `(define p (open-input-string "")) (peek-char p)`, "^#<eof>$" },
+		{ `(eof-object? (eof-object))`, "^#t$" },
+		{ `(eof-object? #\a)`, "^#f$" },
+		{ `(define p (open-input-string "one\ntwo\r\n\nthree"))
+		   (list (read-line p) (read-line p) (read-line p) (read-line p) (eof-object? (read-line p)))`,
+			`^\("one" "two" "" "three" #t\)$` },
+		{ `(char-ready? (open-input-string ""))`, "^#t$" },
+		{ `(define p (open-output-string)) (write 'a p) (display " b" p) (newline p) (get-output-string p)`, `^"a b\\n"$` },
+		{ `(define p (open-output-string)) (write-string "hello" p 1 3) (get-output-string p)`, `^"el"$` },
+		{ `(write-string "hello" (current-output-port) 3 9)`, "Range 3 to 9 is out of range" },
+		{ `(call-with-output-string (lambda (p) (write-char #\x p) (write 1 p)))`, `^"x1"$` },
+		{ `(with-output-to-string (lambda () (display "in") (write "side")))`, `^"in\\"side\\""$` },
+		// The output port comes back, even after an error
+		{ `(with-output-to-string (lambda () (car '())))
+		   (with-output-to-string (lambda () (display 1)))`, `^"1"$` },
+		{ `(eq? (current-output-port)
+		        (with-output-to-string (lambda () (current-output-port))))`, "^#f$" },
+		{ `(list (input-port? (current-input-port)) (output-port? (current-input-port)))`, `^\(#t #f\)$` },
+		{ `(list (port? (current-error-port)) (port? "port"))`, `^\(#t #f\)$` },
+		{ `(read-char (current-output-port))`, "is not an input port" },
+		{ `(write 1 (open-input-string "x"))`, "is not an output port" },
+		{ `(read-char 'p)`, "p is not a port" },
+		{ `(get-output-string (current-output-port))`, "is not a string output port" },
+		{ `(define p (open-input-string "x")) (close-port p) (read-char p)`, "#<closed-input-port string> is closed" },
+		{ `(define p (open-output-string)) (close-port p) (close-port p) (write 1 p)`, "is closed" },
+		{ `(read-char)`, "^#<eof>$" },
+		{ `(open-input-file "x")`, "file access is disabled" },
+	}
+
+	checkBackends(t, tests, func(interp *Interpreter) {
+		interp.SetOutput(&bytes.Buffer{})
+	})
+}
+
+func TestHostPorts(t *testing.T) {
+	var out, errOut bytes.Buffer
+	interp := NewInterpreter()
+	interp.SetInput(strings.NewReader("first line\nsecond"))
+	interp.SetOutput(&out)
+	interp.SetErrorOutput(&errOut)
+	got := lastResult(interp, `
+		(display (read-line))
+		(display "oops" (current-error-port))
+		(read-line)`)
+	if got.Sprint() != `"second"` {
+		t.Errorf("read-line gave %q, want %q", got.Sprint(), `"second"`)
+	}
+	if out.String() != "first line" {
+		t.Errorf("Output was %q, want %q", out.String(), "first line")
+	}
+	if errOut.String() != "oops" {
+		t.Errorf("Error output was %q, want %q", errOut.String(), "oops")
+	}
+}
+
+func TestFilePorts(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"data/in.txt": "line 1\nline 2\n",
+	})
+	outside := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("shh"), 0644) ; err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "data", "link")) ; err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "new"), filepath.Join(dir, "data", "dangling")) ; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(define p (open-input-file "in.txt")) (read-line p) (read-line p)`, `^"line 2"$` },
+		{ `(define p (open-output-file "out.txt"))
+		   (write '(1 "two") p)
+		   (close-port p)
+		   (read-line (open-input-file "out.txt"))`, `^"\(1 \\"two\\"\)"$` },
+		{ `(open-input-file "missing.txt")`, "no such file" },
+		{ `(open-input-file "../../escape.txt")`, "isn't under" },
+		{ `(open-input-file "link/secret")`, "isn't under" },
+		{ `(open-output-file "link/new")`, "isn't under" },
+		{ `(open-output-file "dangling")`, "is a broken link" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetFileRoot(dir)
+		interp.SetDirectory(filepath.Join(dir, "data"))
+		got := lastResult(interp, test.input)
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")) ; err == nil {
+		t.Errorf("Wrote a file outside the file root")
+	}
+}
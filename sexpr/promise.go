@@ -0,0 +1,235 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// Promises, as in R7RS: (delay expr) is a promise to evaluate expr,
+// once, when it's forced.  (delay-force expr) is the same, except that
+// expr gives another promise, and forcing one forces the other; force
+// does that in a loop, not by recursion, so a long chain of them (as a
+// lazy loop makes) doesn't pile up.
+//
+// Following the reference implementation in R7RS, a promise points to
+// a box, which is what changes as it's forced.  Once a delay-force's
+// expression gives the next promise, the two share one box.
+type sexpr_promise struct{
+	*promise
+}
+
+type promise struct{
+	box *promiseBox
+	serialNumber int64
+}
+
+type promiseBox struct{
+	done bool
+	value sexpr_general // once done
+	// What to do to get the value, until done.  For delay-force, it
+	// gives a promise; for delay, it gives the value itself (wrap).
+	thunk func() (sexpr_general, sexpr_error)
+	wrap bool
+}
+
+func mkPromise(box *promiseBox) sexpr_promise {
+	return sexpr_promise{&promise{box, nextSerialNumber()}}
+}
+
+func mkDonePromise(value sexpr_general) sexpr_promise {
+	return mkPromise(&promiseBox{done: true, value: value})
+}
+
+func (p sexpr_promise) Sprint() string {
+	if p.box.done {
+		return "#<promise forced>"
+	}
+	return "#<promise>"
+}
+
+// force gives the promise's value, working it out if need be.  That
+// counts as a call, so a promise that forces itself runs into the
+// depth limit.
+func (p sexpr_promise) force(interp *Interpreter) (sexpr_general, sexpr_error) {
+	for !p.box.done {
+		box := p.box
+		if err := interp.enter() ; err != nil {
+			return nil, err
+		}
+		val, err := box.thunk()
+		interp.leave()
+		if err != nil {
+			return nil, err
+		}
+		if p.box.done {
+			// Forcing it forced it: the thunk forced p itself
+			break
+		}
+		next, ok := val.(sexpr_promise)
+		switch {
+		case box.wrap:
+			next = mkDonePromise(val)
+		case !ok:
+			msg := fmt.Sprintf("%s is not a promise, for delay-force", val.Sprint())
+			return nil, evaluationError{"force", msg}
+		}
+		// p takes on next's state, and next shares p's box from now
+		// on, so forcing either forces both
+		*p.box = *next.box
+		next.box = p.box
+	}
+	return p.box.value, nil
+}
+
+// mkDelay makes delay and delay-force.  The expression is evaluated
+// later, where the delay is.
+func mkDelay(name string, wrap bool) evaluator {
+	return func(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		args, err := unconsifyN(lst, 1)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		return mkPromise(&promiseBox{
+			thunk: func() (sexpr_general, sexpr_error) {
+				return evaluateWithContext(args[0], ctx)
+			},
+			wrap: wrap,
+		}), nil
+	}
+}
+
+// evalConsStream is (cons-stream a b), which is (cons a (delay b))
+func evalConsStream(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	args, err := unconsifyN(lst, 2)
+	if err != nil {
+		return nil, evaluationError{"cons-stream", err.Error()}
+	}
+	car, serr := evaluateWithContext(args[0], ctx)
+	if serr != nil {
+		return nil, serr
+	}
+	cdr, serr := mkDelay("cons-stream", true)(mkList(args[1]), ctx)
+	if serr != nil {
+		return nil, serr
+	}
+	return ctx.mkCons(car, cdr)
+}
+
+func fnForce(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, ok := args[0].(sexpr_promise)
+	if !ok {
+		// R7RS allows forcing anything; it's what it is
+		return args[0], nil
+	}
+	return p.force(ctx.interp)
+}
+
+func fnMakePromise(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	if p, ok := args[0].(sexpr_promise) ; ok {
+		return p, nil
+	}
+	return mkDonePromise(args[0]), nil
+}
+
+// A stream is () or a pair whose cdr is a promise of a stream
+
+func streamPairArgument(s sexpr_general) (sexpr_cons, sexpr_promise, error) {
+	c, ok := s.(sexpr_cons)
+	if ok {
+		if p, ok := c.cdr.(sexpr_promise) ; ok {
+			return c, p, nil
+		}
+	}
+	return c, sexpr_promise{}, fmt.Errorf("%s is not a stream pair", s.Sprint())
+}
+
+func fnStreamCar(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	c, _, err := streamPairArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return c.car, nil
+}
+
+func fnStreamCdr(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	_, p, err := streamPairArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return p.force(ctx.interp)
+}
+
+// streamTake is the first n items of s, as a stream (which is as lazy
+// as s is)
+func streamTake(s sexpr_general, n int64, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if n == 0 || s == atomConstantNil {
+		return atomConstantNil, nil
+	}
+	c, p, err := streamPairArgument(s)
+	if err != nil {
+		return nil, evaluationError{"stream-take", err.Error()}
+	}
+	rest := mkPromise(&promiseBox{
+		thunk: func() (sexpr_general, sexpr_error) {
+			next, err := p.force(ctx.interp)
+			if err != nil {
+				return nil, err
+			}
+			return streamTake(next, n - 1, ctx)
+		},
+		wrap: true,
+	})
+	return ctx.mkCons(c.car, rest)
+}
+
+func fnStreamTake(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	n, err := indexArgument(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return streamTake(args[0], n, ctx)
+}
+
+// fnStreamToList makes a list of the items of a stream, or of the
+// first n of them
+func fnStreamToList(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	n := int64(-1)
+	if len(args) > 1 {
+		var err error
+		if n, err = indexArgument(args[1]) ; err != nil {
+			return nil, err
+		}
+	}
+	var items []sexpr_general
+	for s := args[0] ; s != atomConstantNil && n != 0 ; n-- {
+		c, p, err := streamPairArgument(s)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c.car)
+		var serr sexpr_error
+		if s, serr = p.force(ctx.interp) ; serr != nil {
+			return nil, serr
+		}
+	}
+	return ctx.consifyOnto(items, atomConstantNil)
+}
+
+var promiseFunctions = map[string]applicator {
+	"force":        mkListFn("force", 1, 1, fnForce),
+	"make-promise": mkListFn("make-promise", 1, 1, fnMakePromise),
+	"promise?":     mkTypePredicate("promise?", func(s sexpr_general) bool {
+		_, ok := s.(sexpr_promise)
+		return ok
+	}),
+	"stream-pair?": mkTypePredicate("stream-pair?", func(s sexpr_general) bool {
+		_, _, err := streamPairArgument(s)
+		return err == nil
+	}),
+	"stream-null?": mkTypePredicate("stream-null?", func(s sexpr_general) bool {
+		return s == atomConstantNil
+	}),
+	"stream-car":   mkListFn("stream-car", 1, 1, fnStreamCar),
+	"stream-cdr":   mkListFn("stream-cdr", 1, 1, fnStreamCdr),
+	"stream-take":  mkListFn("stream-take", 2, 2, fnStreamTake),
+	"stream->list": mkListFn("stream->list", 1, 2, fnStreamToList),
+}
@@ -0,0 +1,79 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+const integersFrom = `
+(define integers-from
+  (lambda (n)
+    (cons-stream n (integers-from (+ n 1)))))
+`
+
+func TestPromises(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(force (delay (+ 1 2)))", "^3$" },
+		{ "(delay (car '()))", "^#<promise>$" },
+		{ "(define p (delay 1)) (force p) p", "^#<promise forced>$" },
+		{ "(promise? (delay 1))", "^#t$" },
+		{ "(promise? 1)", "^#f$" },
+		{ "(force 5)", "^5$" },
+		{ "(force (make-promise 5))", "^5$" },
+		{ "(define p (delay 1)) (eq? p (make-promise p))", "^#t$" },
+		{ "(force (delay-force (delay 'deep)))", "^deep$" },
+		{ "(force (delay-force 5))", "5 is not a promise, for delay-force" },
+		// Forced once, remembered after
+		{ `(define count 0)
+		   (define p (delay (let ((c (with-output-to-string (lambda () (display "x"))))) c)))
+		   (list (force p) (eq? (force p) (force p)))`, `^\("x" #t\)$` },
+		{ `(define p (let ((n 2)) (delay (* n n)))) (force p)`, "^4$" },
+		{ `(define f (lambda (a) (delay (+ a 1)))) (force (f 41))`, "^42$" },
+		{ "(force (delay (car '())))", "car" },
+		// A promise that forces itself gets nowhere, but (with a
+		// depth limit) stops
+		{ "(define p (delay (force p))) (force p)", "maximum call depth" },
+		{ "(define p (delay (force p))) (force p) (force (delay 1))", "^1$" },
+		// A long lazy loop doesn't pile up
+		{ `(define loop (lambda (n) (delay-force (cond ((= n 0) (delay 'done)) (else (loop (- n 1)))))))
+		   (force (loop 10000))`, "^done$" },
+	}
+
+	checkBackends(t, tests, func(interp *Interpreter) {
+		interp.SetLimits(Limits{MaxDepth: 10000})
+	})
+}
+
+func TestStreams(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ integersFrom + "(stream-car (stream-cdr (integers-from 1)))", "^2$" },
+		{ integersFrom + "(stream->list (integers-from 1) 5)", `^\(1 2 3 4 5\)$` },
+		{ integersFrom + "(stream->list (stream-take (integers-from 10) 3))", `^\(10 11 12\)$` },
+		{ integersFrom + "(stream-pair? (stream-take (integers-from 10) 3))", "^#t$" },
+		{ integersFrom + "(stream-take (integers-from 10) 0)", `^\(\)$` },
+		{ "(stream->list (cons-stream 1 (cons-stream 2 the-empty-stream)))", `^\(1 2\)$` },
+		{ "(stream->list (stream-take (cons-stream 1 stream-null) 5))", `^\(1\)$` },
+		{ "(stream-null? the-empty-stream)", "^#t$" },
+		{ "(stream-null? (cons-stream 1 2))", "^#f$" },
+		{ "(stream-pair? '(1 2))", "^#f$" },
+		{ "(stream-car '(1 2))", `\(1 2\) is not a stream pair` },
+		{ "(stream->list '(1 2))", `is not a stream pair` },
+		// The rest isn't worked out until it's asked for
+		{ "(stream-car (cons-stream 1 (car '())))", "^1$" },
+		{ "(stream-cdr (cons-stream 1 (car '())))", "car" },
+		{ integersFrom + `
+		   (define stream-map
+		     (lambda (f s)
+		       (cond
+		         ((stream-null? s) the-empty-stream)
+		         (else (cons-stream (f (stream-car s)) (stream-map f (stream-cdr s)))))))
+		   (stream->list (stream-map (lambda (n) (* n n)) (integers-from 1)) 4)`, `^\(1 4 9 16\)$` },
+	}
+
+	checkBackends(t, tests, nil)
+}
@@ -0,0 +1,202 @@
+package sexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// read parses the next datum from a port, with the same parser that
+// reads programs.  The parser wants a channel of everything there is,
+// though, and a port may have more after the datum (or be a terminal,
+// with nothing more yet), so first we find where the datum ends.  That
+// needs only a little of the syntax: brackets, strings, comments, and
+// the #\ of a character.  The parser does the rest.
+
+// isDelimiter says whether r ends an atom, like a symbol or a number
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("()[];\"'", r)
+}
+
+// nextDatumRune is readRune, with the end of the input an error: we're
+// in the middle of a datum
+func (p *port) nextDatumRune() (rune, error) {
+	r, ok, err := p.readRune(false)
+	if err == nil && !ok {
+		err = fmt.Errorf("Unexpected end of input")
+	}
+	return r, err
+}
+
+// skipAtmosphere skips whitespace and comments.  It's false at the end
+// of the input.
+func (p *port) skipAtmosphere() (bool, error) {
+	inComment := false
+	for {
+		r, ok, err := p.readRune(true)
+		if err != nil || !ok {
+			return false, err
+		}
+		switch {
+		case r == ';':
+			inComment = true
+		case r == '\n':
+			inComment = false
+		case !inComment && !unicode.IsSpace(r):
+			return true, nil
+		}
+		p.readRune(false)
+	}
+}
+
+// datumText reads the text of the next datum into b
+func (p *port) datumText(b *strings.Builder) error {
+	r, err := p.nextDatumRune()
+	if err != nil {
+		return err
+	}
+	b.WriteRune(r)
+	switch r {
+	case ')', ']':
+		return fmt.Errorf("Unexpected %q", r)
+	case '\'':
+		if ok, err := p.skipAtmosphere() ; err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("Unexpected end of input")
+		}
+		return p.datumText(b)
+	case '(', '[':
+		return p.listText(b)
+	case '"':
+		return p.stringText(b)
+	case '#':
+		next, ok, err := p.readRune(true)
+		switch {
+		case err != nil:
+			return err
+		case ok && (next == '(' || next == '['):
+			p.readRune(false)
+			b.WriteRune(next)
+			return p.listText(b)
+		case ok && next == '\\':
+			// The character after the backslash is part of it,
+			// even if it's a bracket
+			p.readRune(false)
+			b.WriteRune(next)
+			r, err := p.nextDatumRune()
+			if err != nil {
+				return err
+			}
+			b.WriteRune(r)
+		}
+	}
+	return p.atomText(b)
+}
+
+// atomText reads up to the next delimiter
+func (p *port) atomText(b *strings.Builder) error {
+	for {
+		r, ok, err := p.readRune(true)
+		if err != nil || !ok || isDelimiter(r) {
+			return err
+		}
+		p.readRune(false)
+		b.WriteRune(r)
+	}
+}
+
+// stringText reads the rest of a string, after the opening quote
+func (p *port) stringText(b *strings.Builder) error {
+	for {
+		r, err := p.nextDatumRune()
+		if err != nil {
+			return err
+		}
+		b.WriteRune(r)
+		switch r {
+		case '"':
+			return nil
+		case '\\':
+			r, err := p.nextDatumRune()
+			if err != nil {
+				return err
+			}
+			b.WriteRune(r)
+		}
+	}
+}
+
+// listText reads the rest of a list or vector, after the opening
+// bracket
+func (p *port) listText(b *strings.Builder) error {
+	for {
+		if ok, err := p.skipAtmosphere() ; err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("Unexpected end of input")
+		}
+		if r, _, _ := p.readRune(true) ; r == ')' || r == ']' {
+			p.readRune(false)
+			b.WriteRune(r)
+			return nil
+		}
+		b.WriteRune(' ')
+		if err := p.datumText(b) ; err != nil {
+			return err
+		}
+	}
+}
+
+// readDatum reads the next datum from p, or gives the end-of-file
+// object if there isn't one.  Errors say where the datum started.
+func readDatum(p sexpr_port) (sexpr_general, error) {
+	if ok, err := p.skipAtmosphere() ; err != nil {
+		return nil, err
+	} else if !ok {
+		return eofObject, nil
+	}
+	start := p.position()
+	var b strings.Builder
+	if err := p.datumText(&b) ; err != nil {
+		return nil, fmt.Errorf("Read error at %s: %s", p.position(), err.Error())
+	}
+	parser, sexprs := parse(p.name, mkRuneChannel(b.String()), true)
+	var datum sexpr_general
+	for sx := range sexprs {
+		datum = sx
+	}
+	// If the parser stopped early, the lexer is still waiting to hand
+	// it the rest
+	for range parser.items {
+	}
+	if err := parser.Err() ; err != nil {
+		return nil, fmt.Errorf("Read error at %s: %s", start, err.Error())
+	}
+	if datum == nil {
+		return nil, fmt.Errorf("Read error at %s: no datum in %q", start, b.String())
+	}
+	return datum, nil
+}
+
+func fnRead(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	p, err := inputPortArgument(args, 0, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return readDatum(p)
+}
+
+// fnReadFromString reads the first datum in a string
+func fnReadFromString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	str, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return readDatum(mkInputPort("string", strings.NewReader(str), true))
+}
+
+var readFunctions = map[string]applicator {
+	"read":             mkListFn("read", 0, 1, fnRead),
+	"read-from-string": mkListFn("read-from-string", 1, 1, fnReadFromString),
+}
@@ -0,0 +1,48 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ `(read-from-string "(a (b \"c\") #\\) 1.5)")`, `^\(a \(b "c"\) #\\\) 1.5\)$` },
+		{ `(read-from-string "  ; a comment\n  sym more")`, "^sym$" },
+		{ `(read-from-string "'x")`, `^\(quote x\)$` },
+		{ `(read-from-string "#(1 #t) x")`, `^#\(1 #t\)$` },
+		{ `(read-from-string "#\\space")`, `^#\\space$` },
+		{ `(read-from-string "[a b]")`, `^\(a b\)$` },
+		{ `(eof-object? (read-from-string " ; nothing"))`, "^#t$" },
+		{ `(define p (open-input-string "1 (2 3)\n\"four\" five"))
+		   (list (read p) (read p) (read p) (read p) (eof-object? (read p)))`,
+			`^\(1 \(2 3\) "four" five #t\)$` },
+		// read leaves the rest of the line alone
+		{ `(define p (open-input-string "(a) rest of line"))
+		   (read p)
+		   (read-line p)`, `^" rest of line"$` },
+		{ `(define p (open-input-string "abc)"))
+		   (read p)
+		   (read-char p)`, `^#\\\)$` },
+		// Read what was written
+		{ `(define p (open-output-string))
+		   (write '(1 "two" #\3 #(4)) p)
+		   (equal? (read-from-string (get-output-string p)) '(1 "two" #\3 #(4)))`, "^#t$" },
+		{ `(car (read-from-string "(+ 1 2)"))`, "^\\+$" },
+		// Errors say where
+		{ `(read-from-string "(a b")`, "Read error at line 1, column 5 of string: Unexpected end of input" },
+		{ `(read-from-string ")")`, "Read error at line 1, column 2 of string: Unexpected '\\)'" },
+		{ `(read-from-string "\"abc")`, "Unexpected end of input" },
+		{ `(define p (open-input-string "ok\n  (#\\nonsense)"))
+		   (read p)
+		   (read p)`, "Read error at line 2, column 3 of string: .*Unknown character #\\\\nonsense" },
+		{ `(read-from-string "(a . b)")`, `^\(a \. b\)$` },
+		{ `(read-from-string " (a ''b)")`, "Read error at line 1, column 2 of string: .*invalid quote sequence" },
+		{ `(read 'p)`, "p is not a port" },
+		{ `(read)`, "^#<eof>$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
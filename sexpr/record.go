@@ -0,0 +1,224 @@
+package sexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Records, from R7RS's define-record-type:
+//
+//   (define-record-type <point>
+//     (make-point x y)
+//     point?
+//     (x point-x set-point-x!)
+//     (y point-y))
+//
+// A record is opaque: the only way in is through the procedures its
+// definition makes.  Records are compared (and hashed) by identity,
+// even by equal?.
+
+type recordType struct{
+	name string
+	fields []sexpr_atom
+	serialNumber int64
+}
+
+// A sexpr_record_type is what the type's name (<point>) is bound to
+type sexpr_record_type struct{
+	*recordType
+}
+
+func (t sexpr_record_type) Sprint() string {
+	return fmt.Sprintf("#<record-type %s>", t.name)
+}
+
+type sexpr_record struct{
+	*recordData
+}
+
+type recordData struct{
+	typ *recordType
+	values []sexpr_general
+	serialNumber int64
+	busy bool // being printed (see sexpr_vector)
+}
+
+func (r sexpr_record) Sprint() string {
+	if r.busy {
+		return "#<cycle>"
+	}
+	r.busy = true
+	defer func() { r.busy = false }()
+	parts := []string{r.typ.name}
+	for idx, field := range r.typ.fields {
+		parts = append(parts, field.name + "=" + r.values[idx].Sprint())
+	}
+	return "#<" + strings.Join(parts, " ") + ">"
+}
+
+// fieldIndex finds a field by name
+func (t *recordType) fieldIndex(name sexpr_atom) int {
+	for idx, field := range t.fields {
+		if field == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// recordArgument checks that s is a record of type t
+func (t *recordType) recordArgument(fn string, s sexpr_general) (sexpr_record, sexpr_error) {
+	r, ok := s.(sexpr_record)
+	if !ok || r.typ != t {
+		msg := fmt.Sprintf("%s is not a %s", s.Sprint(), t.name)
+		return r, evaluationError{fn, msg}
+	}
+	return r, nil
+}
+
+func recordSyntaxError(lst sexpr_general, format string, args ...interface{}) sexpr_error {
+	msg := fmt.Sprintf(format, args...)
+	msg += fmt.Sprintf(", in %s", mkCons(mkAtomSymbol("define-record-type"), lst).Sprint())
+	return evaluationError{"define-record-type", msg}
+}
+
+func symbolArgument(s sexpr_general) (sexpr_atom, bool) {
+	a, ok := s.(sexpr_atom)
+	return a, ok && a.typ == atomSymbol
+}
+
+// procedure makes one of the procedures a definition makes
+func (t *recordType) procedure(name sexpr_atom, apply applicator) func_expr {
+	return func_expr{name.name, apply, false, nil, nil, false, nextSerialNumber()}
+}
+
+func (t *recordType) constructor(name sexpr_atom, positions []int) func_expr {
+	return t.procedure(name, func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := checkArity(len(args), len(positions), len(positions)) ; err != nil {
+			return nil, evaluationError{name.name, err.Error()}
+		}
+		if err := ctx.interp.allocate(int64(len(t.fields))) ; err != nil {
+			return nil, err
+		}
+		values := make([]sexpr_general, len(t.fields))
+		for idx := range values {
+			values[idx] = atomConstantFalse
+		}
+		for idx, position := range positions {
+			values[position] = args[idx]
+		}
+		return sexpr_record{&recordData{t, values, nextSerialNumber(), false}}, nil
+	})
+}
+
+func (t *recordType) predicate(name sexpr_atom) func_expr {
+	return t.procedure(name, mkTypePredicate(name.name, func(s sexpr_general) bool {
+		r, ok := s.(sexpr_record)
+		return ok && r.typ == t
+	}))
+}
+
+func (t *recordType) accessor(name sexpr_atom, position int) func_expr {
+	return t.procedure(name, mkNaryFn(name.name, 1, func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		r, err := t.recordArgument(name.name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return r.values[position], nil
+	}))
+}
+
+func (t *recordType) modifier(name sexpr_atom, position int) func_expr {
+	return t.procedure(name, mkNaryFn(name.name, 2, func(args []sexpr_general) (sexpr_general, sexpr_error) {
+		r, err := t.recordArgument(name.name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		r.values[position] = args[1]
+		return unspecified, nil
+	}))
+}
+
+// evalDefineRecordType is (define-record-type name (constructor field
+// ...) predicate (field accessor [modifier]) ...).  It binds them all
+// at top level (or a library's), the only place it's allowed: the
+// compiled backends settle a body's variables before running it, so
+// they'd never see these.
+func evalDefineRecordType(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if ctx.parent != nil {
+		return nil, recordSyntaxError(lst, "Record types can only be defined at top level")
+	}
+	args, err := unconsifyAtLeastN(lst, 3)
+	if err != nil {
+		return nil, recordSyntaxError(lst, "%s", err.Error())
+	}
+	typeName, ok := symbolArgument(args[0])
+	if !ok {
+		return nil, recordSyntaxError(lst, "%s is not a type name", args[0].Sprint())
+	}
+	t := &recordType{
+		name: strings.TrimSuffix(strings.TrimPrefix(typeName.name, "<"), ">"),
+		serialNumber: nextSerialNumber(),
+	}
+	bindings := []sexpr_atom{typeName}
+	values := []sexpr_general{sexpr_record_type{t}}
+
+	// The fields first, since the constructor refers to them
+	for _, spec := range args[3:] {
+		parts, err := unconsify(spec)
+		if err != nil || len(parts) < 2 || len(parts) > 3 {
+			return nil, recordSyntaxError(lst, "%s is not (field accessor [modifier])", spec.Sprint())
+		}
+		names := make([]sexpr_atom, len(parts))
+		for idx, part := range parts {
+			if names[idx], ok = symbolArgument(part) ; !ok {
+				return nil, recordSyntaxError(lst, "%s is not a name", part.Sprint())
+			}
+		}
+		if t.fieldIndex(names[0]) >= 0 {
+			return nil, recordSyntaxError(lst, "The field %s appears twice", names[0].Sprint())
+		}
+		t.fields = append(t.fields, names[0])
+		position := len(t.fields) - 1
+		bindings = append(bindings, names[1])
+		values = append(values, t.accessor(names[1], position))
+		if len(names) == 3 {
+			bindings = append(bindings, names[2])
+			values = append(values, t.modifier(names[2], position))
+		}
+	}
+
+	spec, err := unconsifyAtLeastN(args[1], 1)
+	if err != nil {
+		return nil, recordSyntaxError(lst, "%s is not (constructor field ...)", args[1].Sprint())
+	}
+	constructorName, ok := symbolArgument(spec[0])
+	if !ok {
+		return nil, recordSyntaxError(lst, "%s is not a name", spec[0].Sprint())
+	}
+	var positions []int
+	for _, field := range spec[1:] {
+		name, _ := symbolArgument(field)
+		position := t.fieldIndex(name)
+		if position < 0 {
+			return nil, recordSyntaxError(lst, "%s is not a field", field.Sprint())
+		}
+		positions = append(positions, position)
+	}
+	bindings = append(bindings, constructorName)
+	values = append(values, t.constructor(constructorName, positions))
+
+	predicateName, ok := symbolArgument(args[2])
+	if !ok {
+		return nil, recordSyntaxError(lst, "%s is not a name", args[2].Sprint())
+	}
+	bindings = append(bindings, predicateName)
+	values = append(values, t.predicate(predicateName))
+
+	for idx, name := range bindings {
+		if err := ctx.bind(name, values[idx]) ; err != nil {
+			return nil, evaluationError{"define-record-type", err.Error()}
+		}
+	}
+	return sexpr_definition{typeName}, nil
+}
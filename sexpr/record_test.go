@@ -0,0 +1,60 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+const pointRecord = `
+(define-record-type <point>
+  (make-point x y)
+  point?
+  (x point-x set-point-x!)
+  (y point-y))
+`
+
+func TestRecords(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ pointRecord, "^;; defined <point>$" },
+		{ pointRecord + "(make-point 1 2)", "^#<point x=1 y=2>$" },
+		{ pointRecord + "(point-y (make-point 1 2))", "^2$" },
+		{ pointRecord + "(point? (make-point 1 2))", "^#t$" },
+		{ pointRecord + "(point? '(1 2))", "^#f$" },
+		{ pointRecord + "(point? (vector 1 2))", "^#f$" },
+		{ pointRecord + "(let ((p (make-point 1 2))) (let () (set-point-x! p 5) (point-x p)))", "^5$" },
+		{ pointRecord + "<point>", "^#<record-type point>$" },
+		{ pointRecord + "(point-x 1)", "point-x.*1 is not a point" },
+		{ pointRecord + "(make-point 1)", "make-point.*Expected 2 arguments" },
+		{ pointRecord + "(map point-x (list (make-point 1 2) (make-point 3 4)))", `^\(1 3\)$` },
+		// Fields the constructor leaves out start as #f
+		{ `(define-record-type node (make-node value) node? (value node-value) (next node-next set-node-next!))
+		   (make-node 1)`, "^#<node value=1 next=#f>$" },
+		{ `(define-record-type node (make-node value) node? (value node-value) (next node-next set-node-next!))
+		   (let ((n (make-node 1))) (let () (set-node-next! n n) n))`, "^#<node value=1 next=#<cycle>>$" },
+		// Records are only ever equal to themselves
+		{ pointRecord + "(equal? (make-point 1 2) (make-point 1 2))", "^#f$" },
+		{ pointRecord + "(let ((p (make-point 1 2))) (eq? p p))", "^#t$" },
+		{ pointRecord + `(define t (make-hash-table))
+		   (define p (make-point 1 2))
+		   (hash-table-set! t p "here")
+		   (hash-table-set! t (make-point 1 2) "there")
+		   (list (hash-table-ref t p) (hash-table-count t))`, `^\("here" 2\)$` },
+		// Two types are different, even with the same name
+		{ pointRecord + "(define p (make-point 1 2))" + pointRecord + "(point? p)", "^#f$" },
+		{ "(define-record-type point (make-point x z) point? (x point-x))", "z is not a field" },
+		{ "(define-record-type point (make-point) point? (x point-x) (x point-x2))", "The field x appears twice" },
+		{ "(define-record-type point (make-point) point? x)", "x is not \\(field accessor \\[modifier\\]\\)" },
+		{ "(define-record-type \"point\" (make-point) point?)", "is not a type name" },
+		{ "(define-record-type point (make-point) point? (x car))", "Cannot redefine primitive car" },
+		{ "(let () (define-record-type box (box v) box? (v unbox)) 1)", "only be defined at top level" },
+		// Libraries can define them, though
+		{ `(define-library (shapes) (export make-circle circle-r) (import (scheme base))
+		     (begin (define-record-type circle (make-circle r) circle? (r circle-r))))
+		   (import (shapes))
+		   (circle-r (make-circle 3))`, "^3$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
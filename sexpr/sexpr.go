@@ -9,8 +9,9 @@ import (
 	// "runtime/debug"
 	"os"
 	"strconv"
-	//"strings"
+	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 type atomType int
@@ -19,11 +20,16 @@ const (
 	atomNumber
 	atomSymbol
 	atomBoolean
+	atomString
+	atomCharacter
 )
 
 type sexpr_atom struct {
 	typ atomType
 	name string
+	// Uninterned symbols (see symbol.go) each get their own, so that
+	// they're never the same as any other symbol; it's 0 for the rest
+	serialNumber int64
 }
 
 func (a sexpr_atom) Sprint() string {
@@ -36,8 +42,17 @@ func (a sexpr_atom) Sprint() string {
 		default:
 			panic(fmt.Sprintf("The faux boolean atom %+v", a))
 		}
-	case atomNumber, atomSymbol:
+	case atomNumber:
 		return a.name
+	case atomSymbol:
+		if a.serialNumber != 0 {
+			return "#:" + a.name
+		}
+		return a.name
+	case atomString:
+		return quoteString(a.name)
+	case atomCharacter:
+		return writeCharacter(a.character())
 	default:
 		msg := fmt.Sprintf("Unprintable atom of type %q: %s", a.typ, a)
 		panic(msg)
@@ -69,7 +84,9 @@ func (a sexpr_atom) String() string {
 			a.name,
 		)
 		panic(msg)
-	case atomSymbol: return fmt.Sprintf("Sym(%s)", a.name)
+	case atomSymbol: return fmt.Sprintf("Sym(%s)", a.Sprint())
+	case atomString: return fmt.Sprintf("Str(%s)", quoteString(a.name))
+	case atomCharacter: return fmt.Sprintf("Char(%s)", writeCharacter(a.character()))
 	default:
 		panic(fmt.Sprintf("No way: atom %v", a))
 	}
@@ -79,10 +96,7 @@ func (a sexpr_atom) evaluate(ctx *evaluationContext) (sexpr_general, sexpr_error
 	switch a.typ {
 	case atomSymbol:
 		if val, ok := ctx.lookup(a) ; !ok {
-			return nil, evaluationError{
-				"lookup",
-				fmt.Sprintf("Variable %s is not bound", a),
-			}
+			return nil, unboundVariableError(a)
 		} else {
 			return val, nil
 		}
@@ -92,14 +106,22 @@ func (a sexpr_atom) evaluate(ctx *evaluationContext) (sexpr_general, sexpr_error
 	}
 }
 
+func unboundVariableError(a sexpr_atom) evaluationError {
+	return evaluationError{
+		"lookup",
+		fmt.Sprintf("Variable %s is not bound", a),
+	}
+}
+
 var (
 	// These are really a constant, but we call them variables.
 	// Please don't try to change them.
-	atomConstantNil sexpr_atom = sexpr_atom{atomNil, "nil"}
-	atomConstantTrue sexpr_atom = sexpr_atom{atomBoolean, "t"}
-	atomConstantFalse sexpr_atom = sexpr_atom{atomBoolean, "f"}
+	atomConstantNil sexpr_atom = sexpr_atom{atomNil, "nil", 0}
+	atomConstantTrue sexpr_atom = sexpr_atom{atomBoolean, "t", 0}
+	atomConstantFalse sexpr_atom = sexpr_atom{atomBoolean, "f", 0}
 	atomConstantQuote sexpr_atom = mkAtomSymbol("quote")
 	atomConstantElse sexpr_atom = mkAtomSymbol("else")
+	atomConstantDefine sexpr_atom = mkAtomSymbol("define")
 	atomConstantZero sexpr_atom = mkAtomNumber("0")
 )
 // TODO:  Different string representations of the same number are
@@ -115,7 +137,7 @@ func atomFactory(t atomType, pool map[string]sexpr_atom) func(string) sexpr_atom
 		defer lock.Unlock()
 		atom, ok := pool[s]
 		if !ok {
-			atom = sexpr_atom{t, s}
+			atom = sexpr_atom{t, s, 0}
 			pool[s] = atom
 		}
 		return atom
@@ -125,6 +147,35 @@ func atomFactory(t atomType, pool map[string]sexpr_atom) func(string) sexpr_atom
 var mkAtomSymbol = atomFactory(atomSymbol, atomSymbolPool)
 var mkAtomNumber = atomFactory(atomNumber, atomNumberPool)
 
+// Strings aren't pooled; there could be a lot of them, and they're
+// compared by value anyway.
+func mkAtomString(s string) sexpr_atom { return sexpr_atom{atomString, s, 0} }
+
+// Neither are characters.  The name is the character itself.
+func mkAtomCharacter(r rune) sexpr_atom { return sexpr_atom{atomCharacter, string(r), 0} }
+func (a sexpr_atom) character() rune {
+	r, _ := utf8.DecodeRuneInString(a.name)
+	return r
+}
+
+// quoteString writes s the way the reader reads it
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':  b.WriteString(`\"`)
+		case '\\': b.WriteString(`\\`)
+		case '\n': b.WriteString(`\n`)
+		case '\t': b.WriteString(`\t`)
+		case '\r': b.WriteString(`\r`)
+		default:   b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
 var currentConsNumber int64
 var currentConsNumberLock sync.Mutex
 type sexpr_cons struct {
@@ -134,12 +185,18 @@ type sexpr_cons struct {
 }
 
 func mkCons(car sexpr_general, cdr sexpr_general) sexpr_cons {
+	return sexpr_cons{car, cdr, nextSerialNumber()}
+}
+
+// nextSerialNumber gives each cons cell (and procedure) an identity,
+// for eq?
+func nextSerialNumber() int64 {
 	defer func() {
 		currentConsNumber += 1
 		currentConsNumberLock.Unlock()
 	}()
 	currentConsNumberLock.Lock()
-	return sexpr_cons{car, cdr, currentConsNumber}
+	return currentConsNumber
 }
 // mkList is a helper method to replace
 //
@@ -168,8 +225,8 @@ func (c sexpr_cons) Sprint() string {
 			ptr = cdr
 			// and loop around agian
 		default:
-			msg := fmt.Sprintf("Unprintable CDR %q", cdr)
-			panic(msg)
+			// A vector, say, or a procedure
+			return str + fmt.Sprintf(" . %s)", cdr.Sprint())
 		}
 	}
 	return str
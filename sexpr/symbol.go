@@ -0,0 +1,78 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// Symbols are interned: mkAtomSymbol gives the same atom for the same
+// name, which is what makes (eq? 'a 'a) true.  An uninterned symbol is
+// one that isn't in the pool, so it's only ever eq? to itself; no
+// symbol that's read, or made by string->symbol, is the same, even
+// with the same name.  That's what macro writers want for names that
+// can't clash with anything.  They print as #:name, which can't be
+// read back.
+
+func mkUninternedSymbol(name string) sexpr_atom {
+	return sexpr_atom{atomSymbol, name, nextSerialNumber()}
+}
+
+// fnGensym is (gensym [prefix]), which makes a new uninterned symbol
+// named for the prefix (g, by default), and a number to tell it apart
+func fnGensym(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	prefix := "g"
+	if len(args) > 0 {
+		a, ok := args[0].(sexpr_atom)
+		if !ok || (a.typ != atomString && a.typ != atomSymbol) {
+			return nil, fmt.Errorf("%s is not a string or a symbol", args[0].Sprint())
+		}
+		prefix = a.name
+	}
+	serial := nextSerialNumber()
+	return sexpr_atom{atomSymbol, fmt.Sprintf("%s%d", prefix, serial), serial}, nil
+}
+
+func fnStringToUninternedSymbol(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	name, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkUninternedSymbol(name), nil
+}
+
+func fnStringToSymbol(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	name, err := showString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkAtomSymbol(name), nil
+}
+
+// fnSymbolToString gives the name, without the #: of an uninterned
+// symbol
+func fnSymbolToString(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	a, err := symbolArgumentOrError(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkAtomString(a.name), nil
+}
+
+func fnSymbolInterned(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	a, err := symbolArgumentOrError(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if a.serialNumber == 0 {
+		return atomConstantTrue, nil
+	}
+	return atomConstantFalse, nil
+}
+
+var symbolFunctions = map[string]applicator {
+	"gensym":                     mkListFn("gensym", 0, 1, fnGensym),
+	"generate-uninterned-symbol": mkListFn("generate-uninterned-symbol", 0, 1, fnGensym),
+	"string->uninterned-symbol":  mkListFn("string->uninterned-symbol", 1, 1, fnStringToUninternedSymbol),
+	"symbol-interned?":           mkListFn("symbol-interned?", 1, 1, fnSymbolInterned),
+	"string->symbol":             mkListFn("string->symbol", 1, 1, fnStringToSymbol),
+	"symbol->string":             mkListFn("symbol->string", 1, 1, fnSymbolToString),
+}
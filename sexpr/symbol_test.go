@@ -0,0 +1,41 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(gensym)", `^#:g\d+$` },
+		{ `(gensym "tmp")`, `^#:tmp\d+$` },
+		{ "(generate-uninterned-symbol 'x)", `^#:x\d+$` },
+		{ "(symbol? (gensym))", "^#t$" },
+		{ "(eq? (gensym) (gensym))", "^#f$" },
+		{ "(define g (gensym)) (eq? g g)", "^#t$" },
+		{ "(symbol-interned? 'a)", "^#t$" },
+		{ "(symbol-interned? (gensym))", "^#f$" },
+		{ "(symbol-interned? (string->symbol \"a\"))", "^#t$" },
+		{ `(string->uninterned-symbol "foo")`, "^#:foo$" },
+		// Never the same as a read symbol, whatever its name
+		{ `(eq? (string->uninterned-symbol "foo") 'foo)`, "^#f$" },
+		{ `(equal? (list (string->uninterned-symbol "foo")) '(foo))`, "^#f$" },
+		{ `(eq? (string->uninterned-symbol "foo") (string->uninterned-symbol "foo"))`, "^#f$" },
+		{ "(define g (gensym)) (eq? (string->symbol (symbol->string g)) g)", "^#f$" },
+		{ `(symbol->string (string->uninterned-symbol "foo"))`, `^"foo"$` },
+		{ `(eq? (string->symbol "a") 'a)`, "^#t$" },
+		{ `(with-output-to-string (lambda () (write (list 'a (string->uninterned-symbol "a")))))`, `^"\(a #:a\)"$` },
+		// They work as names, in code that's built
+		{ "(define g (gensym)) (eval (list 'let (list (list g 1)) (list '+ g 2)))", "^3$" },
+		{ "(define g (gensym)) (eval (list 'define g 5)) (list (eval g) (environment-bound? (interaction-environment) (string->symbol (symbol->string g))))", `^\(5 #f\)$` },
+		{ "(define g (string->uninterned-symbol \"x\")) (eval g)", `Variable Sym\(#:x\) is not bound` },
+		{ "(symbol-interned? 1)", "Exception in symbol-interned\\?: 1 is not a symbol" },
+		{ "(symbol->string \"a\")", `Exception in symbol->string: "a" is not a symbol` },
+		{ "(string->uninterned-symbol 'a)", "Exception in string->uninterned-symbol: a is not a string" },
+		{ "(gensym 1)", "Exception in gensym: 1 is not a string or a symbol" },
+	}
+
+	checkBackends(t, tests, nil)
+}
@@ -12,10 +12,54 @@ import (
 type symbolTable map[sexpr_atom]sexpr_general
 
 // evaluationContext is really (currently) just a stack of symbol
-// tables.  It might get more, though.
+// tables, plus a pointer to the Interpreter that owns them (so we
+// can enforce its resource limits).
+//
+// Frames made by compiled code (see compiler.go) keep their variables
+// in slots, in the order given by names, so that the compiled code
+// can find them by position instead of hashing.  Such a frame has a
+// nil sym until something binds a name it didn't know about.
 type evaluationContext struct{
 	sym symbolTable
 	parent *evaluationContext
+	interp *Interpreter
+	names []sexpr_atom
+	slots []sexpr_general
+}
+
+// extend makes a new, empty frame whose parent is e.  It's what
+// "let" and "lambda" use to mask the bindings below them.
+func (e *evaluationContext) extend() *evaluationContext {
+	return &evaluationContext{make(symbolTable), e, e.interp, nil, nil}
+}
+
+// extendSlots makes a new frame whose variables (named by names) live
+// in slots.  Unset slots are nil, which reads as "unbound".
+func (e *evaluationContext) extendSlots(names []sexpr_atom, slots []sexpr_general) *evaluationContext {
+	return &evaluationContext{nil, e, e.interp, names, slots}
+}
+
+// mkCons is like the plain mkCons, but charges the cell against the
+// Interpreter's allocation limit.  Primitives reachable from SCAM
+// code should allocate this way.
+func (e *evaluationContext) mkCons(car sexpr_general, cdr sexpr_general) (sexpr_general, sexpr_error) {
+	if err := e.interp.allocate(1) ; err != nil {
+		return nil, err
+	}
+	return mkCons(car, cdr), nil
+}
+
+// consifyOnto makes the list of items, ending in tail rather than ()
+// (so tail of () makes a proper list), charging for the cells
+func (e *evaluationContext) consifyOnto(items []sexpr_general, tail sexpr_general) (sexpr_general, sexpr_error) {
+	if err := e.interp.allocate(int64(len(items))) ; err != nil {
+		return nil, err
+	}
+	ans := tail
+	for idx := len(items) - 1 ; idx >= 0 ; idx-- {
+		ans = mkCons(items[idx], ans)
+	}
+	return ans, nil
 }
 
 func (e *evaluationContext) dump() string {
@@ -26,6 +70,9 @@ func (e *evaluationContext) dump_helper(depth int) string {
 	for key, val := range e.sym {
 		ans += fmt.Sprintf("val(%s)\t<--\t%s\n", key, val)
 	}
+	for idx, key := range e.names {
+		ans += fmt.Sprintf("val(%s)\t<--\t%s\n", key, e.slots[idx])
+	}
 	ans += "--------------"
 	if e.parent != nil {
 		ans += "\n" + e.parent.dump_helper(1+depth)
@@ -38,23 +85,43 @@ func (e *evaluationContext) bind(key sexpr_atom, val sexpr_general) error {
 		return errors.New(fmt.Sprintf("Cannot bind non-symbol %q", key))
 	}
 	// else
-	// TODO:  Check further; let's not bind Nil, nor any of
-	// the primitives, for instance.
-	// So key needs to be a non-primitive symbol.
-	// (if we _did_ accidentally redefine null?, the symbol-lookup
-	// wouldn't honor the request anyway, but we would create
-	// confusion and delay I'm sure)
+	if e.interp.isProtected(e, key) {
+		return errors.New(fmt.Sprintf("Cannot redefine primitive %s", key.Sprint()))
+	}
+	for idx, name := range e.names {
+		if name == key {
+			e.slots[idx] = val
+			return nil
+		}
+	}
+	if e.sym == nil {
+		e.sym = make(symbolTable)
+	}
 	e.sym[key] = val
+	if e.interp != nil && e == e.interp.root {
+		e.interp.generation += 1
+	}
 	return nil
 }
 
+// get looks for a in this frame only
+func (e *evaluationContext) get(a sexpr_atom) (s sexpr_general, ok bool) {
+	for idx, name := range e.names {
+		if name == a {
+			return e.slots[idx], e.slots[idx] != nil
+		}
+	}
+	s, ok = e.sym[a]
+	return s, ok
+}
+
 func (e *evaluationContext) lookup(a sexpr_atom) (s sexpr_general, ok bool) {
 	if e == nil {
 		return nil, false
 	}
 
 	ptr := e
-	val, ok := ptr.sym[a]
+	val, ok := ptr.get(a)
 	for !ok {
 		// Check the parent context
 		ptr = ptr.parent
@@ -62,7 +129,7 @@ func (e *evaluationContext) lookup(a sexpr_atom) (s sexpr_general, ok bool) {
 			break
 		}
 		// else
-		val, ok = ptr.sym[a]
+		val, ok = ptr.get(a)
 	}
 	return val, ok
 }
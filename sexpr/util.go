@@ -33,7 +33,12 @@ func unconsify(list sexpr_general) ([]sexpr_general, error) {
 			ans = append(ans, l.car)
 			lst = l.cdr
 		default:
-			panic(fmt.Sprintf("Unrecognized S-expression %v", l))
+			// A procedure, say
+			errmsg := fmt.Sprintf(
+				"Unexpected %s in position %d of %s",
+				l.Sprint(), 1+idx, list,
+			)
+			return nil, errors.New(errmsg)
 		}
 	}
 	return ans, nil
@@ -57,6 +62,36 @@ func unconsifyN(list sexpr_general, n int) ([]sexpr_general, error) {
 	}
 }
 
+// Like unconsify, but throws an error if the resulting list is shorter
+// than n.  Good for things with a "body", like lambda.
+func unconsifyAtLeastN(list sexpr_general, n int) ([]sexpr_general, error) {
+	if ans, err := unconsify(list) ; err != nil {
+		return nil, err
+	} else if len(ans) < n {
+		plural := ""
+		if n > 1 {
+			plural = "s"
+		}
+		msg := fmt.Sprintf("Expected at least %d argument%s, got %d",
+			n, plural, len(ans),
+		)
+		return nil, errors.New(msg)
+	} else {
+		return ans, nil
+	}
+}
+
+// sprintBody prints a sequence of S-expressions the way they'd look
+// in the body of a lambda: separated by spaces, with no parentheses
+// around the lot.
+func sprintBody(body []sexpr_general) string {
+	var strs []string
+	for _, s := range body {
+		strs = append(strs, s.Sprint())
+	}
+	return strings.Join(strs, " ")
+}
+
 // Test for equality (not eq?-ness) of expressions.  For everything
 // except Cons-es, it's just identity in the normal Go-sense.  For
 // Cons cells, we need car and cdr to be equal, but not serial number.
@@ -70,6 +105,9 @@ func equalSexpr(a sexpr_general, b sexpr_general) bool {
 		default:
 			return false
 		}
+	case sexpr_vector:
+		b, ok := b.(sexpr_vector)
+		return ok && deepEqualSexpr(a.elements, b.elements)
 	default: return a == b
 	}
 }
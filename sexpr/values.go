@@ -0,0 +1,398 @@
+package sexpr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Multiple values, as in R7RS.  (values a b) makes an object holding
+// both, which call-with-values, let-values and the rest take apart
+// again.  One value is just itself, so (values 1) is 1; anywhere else
+// a values object can be held in a variable, and the REPL prints its
+// items one per line (and nothing at all, for none), but it can't be
+// passed to a procedure, which expects one value per argument.
+type sexpr_values struct{
+	*multipleValues
+}
+
+type multipleValues struct{
+	items []sexpr_general
+}
+
+func mkValues(items []sexpr_general) sexpr_general {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return sexpr_values{&multipleValues{items}}
+}
+
+func (v sexpr_values) Sprint() string {
+	strs := make([]string, len(v.items))
+	for idx, item := range v.items {
+		strs[idx] = item.Sprint()
+	}
+	return strings.Join(strs, "\n")
+}
+
+// checkArguments is an error if any of args is multiple values,
+// unless f is one of the few procedures that take them apart
+func (f func_expr) checkArguments(args []sexpr_general) sexpr_error {
+	if f.takesValues {
+		return nil
+	}
+	for _, arg := range args {
+		if v, ok := arg.(sexpr_values) ; ok {
+			msg := fmt.Sprintf("Got %d values, expected 1", len(v.items))
+			return evaluationError{f.definition, msg}
+		}
+	}
+	return nil
+}
+
+// valuesOf is what s holds: its items, if it's multiple values, or
+// just s
+func valuesOf(s sexpr_general) []sexpr_general {
+	if v, ok := s.(sexpr_values) ; ok {
+		return v.items
+	}
+	return []sexpr_general{s}
+}
+
+func fnValues(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return mkValues(args), nil
+}
+
+// fnCallWithValues calls the producer, with no arguments, and then the
+// consumer with whatever values it gave
+func fnCallWithValues(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	producer, err := procedureArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := procedureArgument(args[1])
+	if err != nil {
+		return nil, err
+	}
+	vals, serr := producer.apply(nil, ctx)
+	if serr != nil {
+		return nil, serr
+	}
+	return consumer.apply(valuesOf(vals), ctx)
+}
+
+// formals are the names that values get bound to: (a b c) for exactly
+// three, (a b . rest) for at least two, with the others in a list, or
+// just rest, for all of them in a list.
+type formals struct{
+	names []sexpr_atom
+	rest  sexpr_atom
+	hasRest bool
+}
+
+func parseFormals(s sexpr_general) (formals, error) {
+	var f formals
+	for s != atomConstantNil {
+		c, ok := s.(sexpr_cons)
+		if !ok {
+			// The rest
+			if f.rest, ok = symbolArgument(s) ; !ok {
+				return f, fmt.Errorf("%s is not a name", s.Sprint())
+			}
+			f.hasRest = true
+			break
+		}
+		name, ok := symbolArgument(c.car)
+		if !ok {
+			return f, fmt.Errorf("%s is not a name", c.car.Sprint())
+		}
+		if name == mkAtomSymbol(".") {
+			// The reader has no dotted lists, so it reads (a . rest)
+			// as three names
+			return f, errors.New(". is not a name (the reader can't make dotted lists)")
+		}
+		f.names = append(f.names, name)
+		s = c.cdr
+	}
+	return f, nil
+}
+
+// fits says why n values don't fit the formals, if they don't
+func (f formals) fits(n int) error {
+	if n < len(f.names) || (!f.hasRest && n > len(f.names)) {
+		expected := fmt.Sprint(len(f.names))
+		if f.hasRest {
+			expected = "at least " + expected
+		}
+		return fmt.Errorf("Got %d values, expected %s", n, expected)
+	}
+	return nil
+}
+
+// bind binds the names to vals in ctx, or says why they don't fit
+func (f formals) bind(vals []sexpr_general, ctx *evaluationContext) error {
+	if err := f.fits(len(vals)) ; err != nil {
+		return err
+	}
+	for idx, name := range f.names {
+		if err := ctx.bind(name, vals[idx]) ; err != nil {
+			return err
+		}
+	}
+	if f.hasRest {
+		rest, err := ctx.consifyOnto(vals[len(f.names):], atomConstantNil)
+		if err != nil {
+			return err
+		}
+		return ctx.bind(f.rest, rest)
+	}
+	return nil
+}
+
+// bindValues evaluates expr in ctx, and binds its values to the
+// formals in target
+func bindValues(name string, spec, expr sexpr_general, ctx, target *evaluationContext) sexpr_error {
+	f, err := parseFormals(spec)
+	if err != nil {
+		return evaluationError{name, err.Error()}
+	}
+	vals, serr := evaluateWithContext(expr, ctx)
+	if serr != nil {
+		return serr
+	}
+	if err := f.bind(valuesOf(vals), target) ; err != nil {
+		return evaluationError{name, err.Error()}
+	}
+	return nil
+}
+
+// let-values, let*-values and receive are rewritten into lets, which
+// every backend compiles, so that their bodies are in tail position
+// for the VM.  Each expression's values are held in a variable no one
+// else can name, and taken apart from there:
+//
+//   (receive (a . rest) expr body ...)
+//
+// becomes
+//
+//   (let ((#:values (<check> expr)))
+//     (let ((a (<item 0> #:values)) (rest (<rest> #:values)))
+//       body ...))
+//
+// where the procedures in angle brackets are quoted into the code, so
+// nothing the program binds can get in their way.
+
+// valuesBinding is one formals and the expression whose values they
+// take, as rewritten
+type valuesBinding struct{
+	held  sexpr_general   // (#:values (<check> expr))
+	names []sexpr_general // (a (<item 0> #:values)) ...
+}
+
+// mkValuesBinding makes the pieces of the let for spec and expr
+func mkValuesBinding(name string, spec, expr sexpr_general) (valuesBinding, sexpr_error) {
+	f, err := parseFormals(spec)
+	if err != nil {
+		return valuesBinding{}, evaluationError{name, err.Error()}
+	}
+	held := mkUninternedSymbol("values")
+	check := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if err := f.fits(len(valuesOf(args[0]))) ; err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		return args[0], nil
+	}
+	b := valuesBinding{held: mkList(held, mkList(quotedProcedure(name, check), expr))}
+	for idx, n := range f.names {
+		idx := idx
+		item := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return valuesOf(args[0])[idx], nil
+		}
+		b.names = append(b.names, mkList(n, mkList(quotedProcedure(name, item), held)))
+	}
+	if f.hasRest {
+		skip := len(f.names)
+		rest := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+			return ctx.consifyOnto(valuesOf(args[0])[skip:], atomConstantNil)
+		}
+		b.names = append(b.names, mkList(f.rest, mkList(quotedProcedure(name, rest), held)))
+	}
+	return b, nil
+}
+
+// quotedProcedure is (quote <procedure>), which evaluates to the
+// procedure itself
+func quotedProcedure(name string, apply applicator) sexpr_general {
+	return mkList(atomConstantQuote, func_expr{name, apply, true, nil, nil, true, nextSerialNumber()})
+}
+
+func mkLetForm(bindings []sexpr_general, body []sexpr_general) sexpr_general {
+	return mkCons(mkAtomSymbol("let"), mkCons(consify(bindings), consify(body)))
+}
+
+// expandValues rewrites let-values (or let*-values, if sequential) with
+// these bindings and body
+func expandValues(name string, bindings []sexpr_general, sequential bool, body []sexpr_general) (sexpr_general, sexpr_error) {
+	parts := make([]valuesBinding, len(bindings))
+	for idx, b := range bindings {
+		kv, err := unconsifyN(b, 2)
+		if err != nil {
+			return nil, evaluationError{name, err.Error()}
+		}
+		var serr sexpr_error
+		if parts[idx], serr = mkValuesBinding(name, kv[0], kv[1]) ; serr != nil {
+			return nil, serr
+		}
+	}
+	if !sequential || len(parts) == 0 {
+		var held, names []sexpr_general
+		for _, p := range parts {
+			held = append(held, p.held)
+			names = append(names, p.names...)
+		}
+		return mkLetForm(held, []sexpr_general{mkLetForm(names, body)}), nil
+	}
+	// Each one inside the last, from the inside out
+	for idx := len(parts) - 1 ; idx >= 0 ; idx-- {
+		p := parts[idx]
+		body = []sexpr_general{mkLetForm([]sexpr_general{p.held}, []sexpr_general{mkLetForm(p.names, body)})}
+	}
+	return body[0], nil
+}
+
+// mkLetValues makes let-values, whose expressions are all evaluated
+// outside, and let*-values, where each sees the names bound before it
+func mkLetValues(name string, sequential bool) evaluator {
+	return func(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		form, err := expandLetValues(name, sequential, lst)
+		if err != nil {
+			return nil, err
+		}
+		return evaluateWithContext(form, ctx)
+	}
+}
+
+func expandLetValues(name string, sequential bool, lst sexpr_general) (sexpr_general, sexpr_error) {
+	args, err := unconsifyAtLeastN(lst, 2)
+	if err != nil {
+		return nil, evaluationError{name, err.Error()}
+	}
+	bindings, err := unconsify(args[0])
+	if err != nil {
+		return nil, evaluationError{name, err.Error()}
+	}
+	return expandValues(name, bindings, sequential, args[1:])
+}
+
+// evalReceive is (receive formals expr body ...), from SRFI 8
+func evalReceive(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	form, err := expandReceive(lst)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateWithContext(form, ctx)
+}
+
+func expandReceive(lst sexpr_general) (sexpr_general, sexpr_error) {
+	args, err := unconsifyAtLeastN(lst, 3)
+	if err != nil {
+		return nil, evaluationError{"receive", err.Error()}
+	}
+	return expandValues("receive", []sexpr_general{mkList(args[0], args[1])}, false, args[2:])
+}
+
+// expandValuesForm gives what the compilers should compile in place
+// of a let-values, let*-values or receive; ok is false for any other
+// form, or one with bad syntax (which the evaluator can complain
+// about when it runs)
+func expandValuesForm(m macro_expr, lst sexpr_general) (sexpr_general, bool) {
+	var form sexpr_general
+	var err sexpr_error
+	switch m.definition {
+	case "let-values":
+		form, err = expandLetValues("let-values", false, lst)
+	case "let*-values":
+		form, err = expandLetValues("let*-values", true, lst)
+	case "receive":
+		form, err = expandReceive(lst)
+	default:
+		return nil, false
+	}
+	return form, err == nil
+}
+
+// evalDefineValues is (define-values formals expr) at top level (or
+// a library's).  At the start of a body it's a definition like any
+// other, which evalBody (or the compilers) see to; anywhere else it's
+// as out of place as a define would be.
+func evalDefineValues(lst sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if ctx.parent != nil {
+		return nil, evaluationError{
+			"define-values(syntax)",
+			fmt.Sprintf("Invalid context for definition %s",
+				mkCons(mkAtomSymbol("define-values"), lst).Sprint()),
+		}
+	}
+	if err := defineValues(lst, ctx) ; err != nil {
+		return nil, err
+	}
+	// ;; defined a, and so on, one per line
+	args, _ := unconsifyN(lst, 2)
+	f, _ := parseFormals(args[0])
+	names := f.names
+	if f.hasRest {
+		names = append(names, f.rest)
+	}
+	definitions := make([]sexpr_general, len(names))
+	for idx, name := range names {
+		definitions[idx] = sexpr_definition{name}
+	}
+	return mkValues(definitions), nil
+}
+
+// defineValues binds the names of a (define-values formals expr) in
+// ctx.  lst is the cdr of the form.
+func defineValues(lst sexpr_general, ctx *evaluationContext) sexpr_error {
+	args, err := unconsifyN(lst, 2)
+	if err != nil {
+		return evaluationError{"define-values", err.Error()}
+	}
+	return bindValues("define-values", args[0], args[1], ctx, ctx)
+}
+
+// expandDefineValues rewrites the cdr of a define-values at the start
+// of a body as the cdrs of defines, the way mkValuesBinding does for
+// let-values, so that the compilers can give each name a slot:
+//
+//   (define-values (a . rest) expr)
+//
+// becomes
+//
+//   (define #:values (<check> expr))
+//   (define a (<item 0> #:values))
+//   (define rest (<rest> #:values))
+//
+// Bad syntax becomes a definition whose value is the error.
+func expandDefineValues(lst sexpr_general) []sexpr_general {
+	args, err := unconsifyN(lst, 2)
+	if err != nil {
+		return failedDefinition(evaluationError{"define-values", err.Error()})
+	}
+	b, serr := mkValuesBinding("define-values", args[0], args[1])
+	if serr != nil {
+		return failedDefinition(serr)
+	}
+	return append([]sexpr_general{b.held}, b.names...)
+}
+
+func failedDefinition(err sexpr_error) []sexpr_general {
+	fail := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		return nil, err
+	}
+	return []sexpr_general{mkList(mkUninternedSymbol("values"), mkList(quotedProcedure("define-values", fail)))}
+}
+
+var valuesFunctions = map[string]applicator {
+	"values":           mkListFn("values", 0, -1, fnValues),
+	"call-with-values": mkListFn("call-with-values", 2, 2, fnCallWithValues),
+}
@@ -0,0 +1,56 @@
+package sexpr
+
+import (
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "(values 1 2)", "^1\n2$" },
+		{ "(values 'a)", "^a$" },
+		{ "(values)", "^$" },
+		{ "(call-with-values (lambda () (values 1 2)) +)", "^3$" },
+		{ "(call-with-values (lambda () 5) list)", `^\(5\)$` },
+		{ "(call-with-values (lambda () (values)) list)", `^\(\)$` },
+		{ "(call-with-values 1 list)", "Exception in call-with-values: 1 is not a procedure" },
+		{ "(call-with-values (lambda () (values 1 2)) (lambda (a) a))", "Evaluation with 2 arguments, expected 1" },
+		// Multiple values are not one value
+		{ "(list (values 1 2))", "^Exception in list: Got 2 values, expected 1$" },
+		{ "(+ (values 1 2) 1)", `^Exception in \+: Got 2 values, expected 1$` },
+		{ "(define f (lambda () (values))) (car (f))", "^Exception in car: Got 0 values, expected 1$" },
+		{ "(define v (values 1 2)) ((lambda (x) x) v)", "Got 2 values, expected 1$" },
+		{ "(let-values (((a b) (values 1 2)) ((c) (values 3))) (list a b c))", `^\(1 2 3\)$` },
+		// The reader doesn't do dotted lists, but eval does
+		{ "(eval (list 'let-values (list (list (cons 'a 'rest) '(values 1 2 3))) '(list a rest)))", `^\(1 \(2 3\)\)$` },
+		{ "(let-values ((all (values 1 2))) all)", `^\(1 2\)$` },
+		{ "(let-values (((q r) (floor/ 7 2))) (list q r))", `^\(3 1\)$` },
+		// let-values evaluates outside; let*-values, in order
+		{ "(define a 'outer) (let-values (((a) (values 1)) ((b) (values a))) b)", "^outer$" },
+		{ "(let*-values (((a b) (values 1 2)) ((c) (values (+ a b)))) c)", "^3$" },
+		{ "(let-values (((a b) (values 1 2 3))) a)", "Exception in let-values: Got 3 values, expected 2" },
+		{ "(eval (list 'receive (cons 'a (cons 'b 'c)) '(values 1) 'a))", "Got 1 values, expected at least 2" },
+		{ "(let-values (((a 1) (values 1 2))) a)", "Exception in let-values: 1 is not a name" },
+		{ "(receive (a . rest) (values 1 2 3) rest)", `Exception in receive: \. is not a name` },
+		{ "(define-values (x y . z) (values 1 2 3))", `Exception in define-values: \. is not a name` },
+		{ "(define-values (x y . z) (values 1 2 3)) x", "x\\) is not bound" },
+		{ "(receive (in out) (partition odd? '(1 2 3)) (list in out))", `^\(\(1 3\) \(2\)\)$` },
+		{ "(receive all (values 1 2) all)", `^\(1 2\)$` },
+		{ "(define f (lambda (x) (receive (s r) (exact-integer-sqrt x) (+ s r)))) (f 17)", "^5$" },
+		{ "(define-values (q r) (truncate/ 7 2)) (list q r)", `^\(3 1\)$` },
+		{ "(define-values (q r) (floor/ 7 2))", "^;; defined q\n;; defined r$" },
+		{ "(define-values all (values 1 2 3)) all", `^\(1 2 3\)$` },
+		{ "(define f (lambda () (define-values (a) 1) a)) (f)", "^1$" },
+		// In a body, among its other definitions
+		{ "(define f (lambda (x) (define-values (q r) (floor/ x 3)) (define s (+ q r)) (list q r s))) (f 7)", `^\(2 1 3\)$` },
+		{ "(let () (define g (lambda () a)) (define-values all (values 1 2)) (define-values (a b) (values (car all) (length all))) (list (g) b))", `^\(1 2\)$` },
+		{ "(let () (define-values (a b) (values 1)) a)", "Exception in define-values: Got 1 values, expected 2" },
+		{ "(let () (define-values (a 1) (values 1 2)) a)", "Exception in define-values: 1 is not a name" },
+		{ "(let () (define-values (a) 1))", "Body has definitions but no expression" },
+		{ "(let () 1 (define-values (a) 1))", `Invalid context for definition \(define-values \(a\) 1\)` },
+	}
+
+	checkBackends(t, tests, nil)
+}
@@ -0,0 +1,280 @@
+package sexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A vector is a fixed-length run of S-expressions, indexed in constant
+// time.  Unlike a list, it can be changed in place (by vector-set!),
+// so every copy of a sexpr_vector shares the one vectorData.
+type sexpr_vector struct{
+	*vectorData
+}
+
+type vectorData struct{
+	elements []sexpr_general
+	serialNumber int64 // for hashing by identity
+	// Set while the vector is being printed or compared, so a vector
+	// that contains itself doesn't send us around forever
+	busy bool
+}
+
+func mkVector(elements []sexpr_general) sexpr_vector {
+	return sexpr_vector{&vectorData{elements: elements, serialNumber: nextSerialNumber()}}
+}
+
+func (v sexpr_vector) Sprint() string {
+	if v.busy {
+		return "#<cycle>"
+	}
+	v.busy = true
+	defer func() { v.busy = false }()
+	strs := make([]string, len(v.elements))
+	for idx, elt := range v.elements {
+		strs[idx] = elt.Sprint()
+	}
+	return "#(" + strings.Join(strs, " ") + ")"
+}
+
+func (v sexpr_vector) String() string {
+	return fmt.Sprintf("Vector%v", v.elements)
+}
+
+// equalVectors is equal? for vectors.  A vector met again while it's
+// still being compared is taken to be equal, which is what keeps
+// circular ones from comparing forever.
+func equalVectors(a, b sexpr_vector) bool {
+	if a == b || a.busy {
+		return true
+	}
+	if len(a.elements) != len(b.elements) {
+		return false
+	}
+	a.busy = true
+	defer func() { a.busy = false }()
+	for idx, elt := range a.elements {
+		if !isEqual(elt, b.elements[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mkVector is like the plain mkVector, but charges for the elements
+// (each as much as a cons cell) against the allocation limit
+func (e *evaluationContext) mkVector(elements []sexpr_general) (sexpr_general, sexpr_error) {
+	if err := e.interp.allocate(int64(len(elements))) ; err != nil {
+		return nil, err
+	}
+	return mkVector(elements), nil
+}
+
+func vectorArgument(s sexpr_general) (sexpr_vector, error) {
+	v, ok := s.(sexpr_vector)
+	if !ok {
+		return v, fmt.Errorf("%s is not a vector", s.Sprint())
+	}
+	return v, nil
+}
+
+// indexArgument reads a non-negative exact integer, like a length
+func indexArgument(s sexpr_general) (int64, error) {
+	n, err := parseIntOrFloat(s)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := n.integer()
+	if err != nil {
+		return 0, err
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("Index %d is negative", idx)
+	}
+	return idx, nil
+}
+
+// vectorIndex reads an index into v
+func vectorIndex(v sexpr_vector, s sexpr_general) (int, error) {
+	idx, err := indexArgument(s)
+	if err != nil {
+		return 0, err
+	}
+	if idx >= int64(len(v.elements)) {
+		return 0, fmt.Errorf("Index %d is out of range for %s", idx, v.Sprint())
+	}
+	return int(idx), nil
+}
+
+// vectorRange reads the optional start and end arguments that say
+// which part of v to use (all of it, by default)
+func vectorRange(v sexpr_vector, args []sexpr_general) (int, int, error) {
+	start, end := int64(0), int64(len(v.elements))
+	var err error
+	if len(args) > 0 {
+		if start, err = indexArgument(args[0]) ; err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(args) > 1 {
+		if end, err = indexArgument(args[1]) ; err != nil {
+			return 0, 0, err
+		}
+	}
+	if start > end || end > int64(len(v.elements)) {
+		return 0, 0, fmt.Errorf("Range %d to %d is out of range for %s", start, end, v.Sprint())
+	}
+	return int(start), int(end), nil
+}
+
+func fnMakeVector(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	n, err := indexArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var fill sexpr_general = atomConstantFalse
+	if len(args) > 1 {
+		fill = args[1]
+	}
+	// Charge before making it, in case it's enormous
+	if err := ctx.interp.allocate(n) ; err != nil {
+		return nil, err
+	}
+	elements := make([]sexpr_general, n)
+	for idx := range elements {
+		elements[idx] = fill
+	}
+	return mkVector(elements), nil
+}
+
+func fnVector(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	return ctx.mkVector(append([]sexpr_general(nil), args...))
+}
+
+func fnVectorRef(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	v, err := vectorArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	idx, err := vectorIndex(v, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return v.elements[idx], nil
+}
+
+func fnVectorSet(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	v, err := vectorArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	idx, err := vectorIndex(v, args[1])
+	if err != nil {
+		return nil, err
+	}
+	v.elements[idx] = args[2]
+	return unspecified, nil
+}
+
+func fnVectorLength(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	v, err := vectorArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mkInt(int64(len(v.elements))).sexprize(), nil
+}
+
+func fnVectorToList(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	v, err := vectorArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := vectorRange(v, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return ctx.consifyOnto(v.elements[start:end], atomConstantNil)
+}
+
+func fnListToVector(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	var elements []sexpr_general
+	_, err := walkList(args[0], func(elt sexpr_general) (bool, error) {
+		elements = append(elements, elt)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ctx.mkVector(elements)
+}
+
+func fnVectorFill(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+	v, err := vectorArgument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := vectorRange(v, args[2:])
+	if err != nil {
+		return nil, err
+	}
+	for idx := start ; idx < end ; idx++ {
+		v.elements[idx] = args[1]
+	}
+	return unspecified, nil
+}
+
+// mkVectorMapper makes vector-map and vector-for-each, which are map
+// and for-each for vectors.  They stop at the end of the shortest.
+func mkVectorMapper(keep bool) func([]sexpr_general, *evaluationContext) (sexpr_general, error) {
+	return func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, error) {
+		f, err := procedureArgument(args[0])
+		if err != nil {
+			return nil, err
+		}
+		vectors := make([]sexpr_vector, len(args) - 1)
+		n := -1
+		for idx, arg := range args[1:] {
+			if vectors[idx], err = vectorArgument(arg) ; err != nil {
+				return nil, err
+			}
+			if n < 0 || len(vectors[idx].elements) < n {
+				n = len(vectors[idx].elements)
+			}
+		}
+		var results []sexpr_general
+		for idx := 0 ; idx < n ; idx++ {
+			elts := make([]sexpr_general, len(vectors))
+			for j, v := range vectors {
+				elts[j] = v.elements[idx]
+			}
+			ans, err := f.apply(elts, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				results = append(results, ans)
+			}
+		}
+		if !keep {
+			return unspecified, nil
+		}
+		return ctx.mkVector(results)
+	}
+}
+
+var vectorFunctions = map[string]applicator {
+	"vector?": mkTypePredicate("vector?", func(s sexpr_general) bool {
+		_, ok := s.(sexpr_vector)
+		return ok
+	}),
+	"make-vector":     mkListFn("make-vector", 1, 2, fnMakeVector),
+	"vector":          mkListFn("vector", 0, -1, fnVector),
+	"vector-ref":      mkListFn("vector-ref", 2, 2, fnVectorRef),
+	"vector-set!":     mkListFn("vector-set!", 3, 3, fnVectorSet),
+	"vector-length":   mkListFn("vector-length", 1, 1, fnVectorLength),
+	"vector->list":    mkListFn("vector->list", 1, 3, fnVectorToList),
+	"list->vector":    mkListFn("list->vector", 1, 1, fnListToVector),
+	"vector-fill!":    mkListFn("vector-fill!", 2, 4, fnVectorFill),
+	"vector-map":      mkListFn("vector-map", 2, -1, mkVectorMapper(true)),
+	"vector-for-each": mkListFn("vector-for-each", 2, -1, mkVectorMapper(false)),
+}
@@ -0,0 +1,62 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		{ "#(1 2 3)", `^#\(1 2 3\)$` },
+		{ "#()", `^#\(\)$` },
+		{ "'#(a (b c) #(d))", `^#\(a \(b c\) #\(d\)\)$` },
+		{ "(vector? #(1))", "^#t$" },
+		{ "(vector? '(1))", "^#f$" },
+		{ "(vector 1 'a \"b\")", `^#\(1 a "b"\)$` },
+		{ "(make-vector 2 'x)", `^#\(x x\)$` },
+		{ "(make-vector 2)", `^#\(#f #f\)$` },
+		{ "(make-vector -1)", "Index -1 is negative" },
+		{ "(vector-ref #(a b c) 2)", "^c$" },
+		{ "(vector-ref #(a b c) 3)", `Exception in vector-ref: Index 3 is out of range for #\(a b c\)` },
+		{ "(vector-ref '(a b c) 0)", `\(a b c\) is not a vector` },
+		{ "(vector-length #(a b c))", "^3$" },
+		{ "(define v (vector 1 2 3)) (vector-set! v 0 'x) v", `^#\(x 2 3\)$` },
+		{ "(define v (vector 1 2 3)) (define w v) (vector-set! v 0 'x) w", `^#\(x 2 3\)$` },
+		{ "(define v (vector 1 2 3)) (vector-fill! v 0) v", `^#\(0 0 0\)$` },
+		{ "(define v (vector 1 2 3)) (vector-fill! v 0 1 2) v", `^#\(1 0 3\)$` },
+		{ "(vector->list #(1 2 3))", `^\(1 2 3\)$` },
+		{ "(vector->list #(1 2 3) 1)", `^\(2 3\)$` },
+		{ "(vector->list #(1 2 3) 1 2)", `^\(2\)$` },
+		{ "(vector->list #(1 2 3) 2 1)", "Range 2 to 1 is out of range" },
+		{ "(list->vector '(1 (2)))", `^#\(1 \(2\)\)$` },
+		{ "(list->vector 1)", "Unexpected atom" },
+		{ "(vector-map (lambda (x) (* x x)) #(1 2 3))", `^#\(1 4 9\)$` },
+		{ "(vector-map + #(1 2) #(10 20 30))", `^#\(11 22\)$` },
+		{ "(vector-map car #(1))", "car" },
+		{ "(call/cc (lambda (k) (vector-for-each (lambda (x) (cond ((< x 0) (k x)))) #(1 -2 3))))", "^-2$" },
+		{ "(vector-for-each car #())", "^$" },
+		// Identity and structure
+		{ "(define v #(1)) (eq? v v)", "^#t$" },
+		{ "(eq? (vector 1) (vector 1))", "^#f$" },
+		{ "(equal? (vector 1 '(2)) #(1 (2)))", "^#t$" },
+		{ "(equal? #(1 2) #(1 3))", "^#f$" },
+		{ "(cons 1 #(2))", `^\(1 \. #\(2\)\)$` },
+		// A vector can contain itself
+		{ "(define v (vector 1 2)) (vector-set! v 1 v) v", `^#\(1 #<cycle>\)$` },
+		{ "(define v (vector 1 2)) (vector-set! v 1 v) (equal? v v)", "^#t$" },
+	}
+
+	checkBackends(t, tests, nil)
+}
+
+func TestVectorsAllocate(t *testing.T) {
+	interp := NewInterpreter()
+	interp.SetLimits(Limits{MaxConses: 100})
+	got := lastResult(interp, "(make-vector 1000000)")
+	if ok, _ := regexp.MatchString("Exceeded the limit of 100 cons cells", got.Sprint()) ; !ok {
+		t.Errorf("A huge vector gave %q", got.Sprint())
+	}
+}
@@ -0,0 +1,370 @@
+package sexpr
+
+import (
+	"fmt"
+)
+
+// The VM runs the bytecode made in bytecode.go.  Its stack and its
+// frames are explicit (Go slices, not Go recursion), which makes two
+// things cheap:
+//
+//   * A tail call replaces the caller's frame, so a loop written as a
+//     tail-recursive procedure runs in constant space.
+//   * call/cc just copies the stack and the frames, and invoking the
+//     continuation puts them back.
+//
+// A procedure made by the VM is an ordinary func_expr, so primitives
+// (and the other backends) can call it; they get a fresh machine
+// for the purpose.  A continuation invoked from inside such a call
+// unwinds back out, as an error, to the machine that can resume it.
+
+// vmProcedure is what a func_expr made by the VM carries.  It's
+// either a closure or a continuation.
+type vmProcedure struct{
+	template *vmTemplate
+	env *evaluationContext
+	continuation *vmContinuation
+}
+
+type vmFrame struct{
+	template *vmTemplate
+	pc int
+	env *evaluationContext
+	base int // where this frame's part of the stack starts
+}
+
+type vm struct{
+	interp *Interpreter
+	stack []sexpr_general
+	frames []vmFrame
+	// A top-level machine runs a whole top-level form, so its
+	// bottom frame returns to the REPL rather than to Go code.
+	toplevel bool
+}
+
+// A vmContinuation is a snapshot of a machine.  Continuations made
+// outside the VM (by the other backends) have no machine and can only
+// escape, not re-enter.
+type vmContinuation struct{
+	machine *vm
+	toplevel bool
+	stack []sexpr_general
+	frames []vmFrame
+	depth int
+}
+
+// continuationInvoked is the "error" that carries a value back to the
+// continuation it was given to, through any Go code in the way.
+type continuationInvoked struct{
+	k *vmContinuation
+	value sexpr_general
+}
+func (c continuationInvoked) Error() string {
+	return "Exception in call/cc: Continuation invoked outside of its extent"
+}
+func (c continuationInvoked) Sprint() string { return c.Error() }
+
+func mkContinuation(k *vmContinuation) func_expr {
+	p := &vmProcedure{continuation: k}
+	apply := func(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+		if len(args) != 1 {
+			msg := fmt.Sprintf("Expected 1 arguments, got %d", len(args))
+			return nil, evaluationError{"continuation", msg}
+		}
+		return nil, continuationInvoked{k, args[0]}
+	}
+	return func_expr{"continuation", apply, false, p, nil, false, nextSerialNumber()}
+}
+
+// fnCallCC is call/cc for everyone but the VM (which does its own
+// thing; see vm.call).  The continuation can only escape.
+func fnCallCC(args []sexpr_general, ctx *evaluationContext) (sexpr_general, sexpr_error) {
+	if len(args) != 1 {
+		msg := fmt.Sprintf("Expected 1 arguments, got %d", len(args))
+		return nil, evaluationError{"call/cc", msg}
+	}
+	f, ok := args[0].(func_expr)
+	if !ok {
+		msg := fmt.Sprintf("%s is not a procedure", args[0].Sprint())
+		return nil, evaluationError{"call/cc", msg}
+	}
+	k := &vmContinuation{}
+	val, err := f.apply([]sexpr_general{mkContinuation(k)}, ctx)
+	if ci, ok := err.(continuationInvoked) ; ok && ci.k == k {
+		return ci.value, nil
+	}
+	return val, err
+}
+
+func isCallCC(f func_expr) bool {
+	return f.primitive &&
+		(f.definition == "call/cc" || f.definition == "call-with-current-continuation")
+}
+
+func fnDisassemble(args []sexpr_general) (sexpr_general, sexpr_error) {
+	if f, ok := args[0].(func_expr) ; ok && f.vm != nil && f.vm.template != nil {
+		return f.vm.template.disassemble(), nil
+	}
+	msg := fmt.Sprintf("%s was not compiled to bytecode", args[0].Sprint())
+	return nil, evaluationError{"disassemble", msg}
+}
+
+func (t *vmTemplate) closure(env *evaluationContext) func_expr {
+	p := &vmProcedure{template: t, env: env}
+	return func_expr{t.definition, p.apply, false, p, nil, false, nextSerialNumber()}
+}
+
+// frame makes the environment for a call of the closure p
+func (p *vmProcedure) frame(args []sexpr_general) (*evaluationContext, sexpr_error) {
+	t := p.template
+	if len(args) != t.nparams {
+		return nil, evaluationError{
+			t.definition,
+			fmt.Sprintf("Evaluation with %d arguments, expected %d", len(args), t.nparams),
+		}
+	}
+	slots := make([]sexpr_general, len(t.names))
+	for idx, arg := range args {
+		slots[t.positions[idx]] = arg
+	}
+	return p.env.extendSlots(t.names, slots), nil
+}
+
+// apply is how Go code calls a VM closure: on a machine of its own
+func (p *vmProcedure) apply(args []sexpr_general, caller *evaluationContext) (sexpr_general, sexpr_error) {
+	env, err := p.frame(args)
+	if err != nil {
+		return nil, err
+	}
+	interp := p.env.interp
+	if err := interp.enter() ; err != nil {
+		return nil, err
+	}
+	defer interp.leave()
+	m := &vm{interp: interp}
+	m.frames = []vmFrame{{p.template, 0, env, 0}}
+	return m.run()
+}
+
+// runTopLevel runs a template made by assembleTopLevel.  The forms of
+// a file being loaded don't count as top level here, because they
+// return to "load" rather than to the REPL.
+func (i *Interpreter) runTopLevel(t *vmTemplate) (sexpr_general, sexpr_error) {
+	m := &vm{interp: i, toplevel: i.nested == 0}
+	m.frames = []vmFrame{{t, 0, i.root, 0}}
+	return m.run()
+}
+
+func (m *vm) push(s sexpr_general) { m.stack = append(m.stack, s) }
+func (m *vm) pop() sexpr_general {
+	top := m.stack[len(m.stack) - 1]
+	m.stack = m.stack[:len(m.stack) - 1]
+	return top
+}
+
+func (m *vm) capture() *vmContinuation {
+	return &vmContinuation{
+		machine: m,
+		toplevel: m.toplevel,
+		stack: append([]sexpr_general(nil), m.stack...),
+		frames: append([]vmFrame(nil), m.frames...),
+		depth: m.interp.depth,
+	}
+}
+
+// canResume says whether k's snapshot can replace m's state.  Any
+// top-level machine will do for a top-level continuation, since they
+// all return to the same place.
+func (m *vm) canResume(k *vmContinuation) bool {
+	return k.machine != nil && (k.machine == m || (k.toplevel && m.toplevel))
+}
+
+func (m *vm) resume(k *vmContinuation, val sexpr_general) {
+	m.stack = append([]sexpr_general(nil), k.stack...)
+	m.frames = append([]vmFrame(nil), k.frames...)
+	m.interp.depth = k.depth
+	m.push(val)
+}
+
+func (m *vm) run() (sexpr_general, sexpr_error) {
+	startDepth := m.interp.depth
+	defer func() { m.interp.depth = startDepth }()
+	for {
+		val, err := m.loop()
+		if err == nil {
+			return val, nil
+		}
+		if ci, ok := err.(continuationInvoked) ; ok && m.canResume(ci.k) {
+			m.resume(ci.k, ci.value)
+			continue
+		}
+		return nil, err
+	}
+}
+
+func (m *vm) loop() (sexpr_general, sexpr_error) {
+	interp := m.interp
+	for {
+		f := &m.frames[len(m.frames) - 1]
+		ins := f.template.code[f.pc]
+		f.pc += 1
+		switch ins.op() {
+		case opConst:
+			m.push(f.template.constants[ins.a()])
+		case opLocal:
+			env := f.env
+			for depth := ins.a12() ; depth > 0 ; depth-- {
+				env = env.parent
+			}
+			val := env.slots[ins.b12()]
+			if val == nil {
+				return nil, unboundVariableError(env.names[ins.b12()])
+			}
+			m.push(val)
+		case opGlobal:
+			sym := f.template.constants[ins.a()].(sexpr_atom)
+			val, ok := interp.root.get(sym)
+			if !ok {
+				return nil, unboundVariableError(sym)
+			}
+			m.push(val)
+		case opSetLocal:
+			f.env.slots[ins.a()] = m.pop()
+		case opDefine:
+			key := f.template.constants[ins.a()].(sexpr_atom)
+			if err := f.env.bind(key, m.pop()) ; err != nil {
+				return nil, evaluationError{"define(binding)", err.Error()}
+			}
+			m.push(sexpr_definition{key})
+		case opPop:
+			m.pop()
+		case opJump:
+			f.pc = ins.a()
+		case opJumpFalse:
+			if isFalsey(m.pop()) {
+				f.pc = ins.a()
+			}
+		case opJumpTrue:
+			if !isFalsey(m.pop()) {
+				f.pc = ins.a()
+			}
+		case opClosure:
+			m.push(f.template.templates[ins.a()].closure(f.env))
+		case opBind:
+			if err := interp.step() ; err != nil {
+				return nil, err
+			}
+			sc := f.template.scopes[ins.a12()]
+			n := ins.b12()
+			slots := make([]sexpr_general, len(sc.names))
+			for idx, val := range m.stack[len(m.stack) - n:] {
+				slots[sc.positions[idx]] = val
+			}
+			m.stack = m.stack[:len(m.stack) - n]
+			f.env = f.env.extendSlots(sc.names, slots)
+		case opUnbind:
+			f.env = f.env.parent
+		case opMacro:
+			if err := interp.step() ; err != nil {
+				return nil, err
+			}
+			macro := f.template.constants[ins.a12()].(macro_expr)
+			val, err := macro.apply(f.template.constants[ins.b12()], f.env)
+			if err != nil {
+				return nil, err
+			}
+			m.push(val)
+		case opReturn:
+			val := m.pop()
+			m.stack = m.stack[:f.base]
+			m.frames = m.frames[:len(m.frames) - 1]
+			if len(m.frames) == 0 {
+				return val, nil
+			}
+			interp.leave()
+			m.push(val)
+		case opCall, opTailCall:
+			if err := interp.step() ; err != nil {
+				return nil, err
+			}
+			err := m.call(ins.a12(), f.template.constants[ins.b12()], ins.op() == opTailCall)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			panic(fmt.Sprintf("Unknown opcode %d", ins.op()))
+		}
+	}
+}
+
+// call calls the procedure on the stack, under its n arguments.  lst
+// is the unevaluated cdr of the call, in case it turns out to be a
+// macro.
+func (m *vm) call(n int, lst sexpr_general, tail bool) sexpr_error {
+	f := &m.frames[len(m.frames) - 1]
+	at := len(m.stack) - n - 1
+	switch callee := m.stack[at].(type) {
+	case func_expr:
+		if err := callee.checkArguments(m.stack[at + 1:]) ; err != nil {
+			return err
+		}
+		switch {
+		case callee.vm != nil && callee.vm.template != nil:
+			env, err := callee.vm.frame(m.stack[at + 1:])
+			if err != nil {
+				return err
+			}
+			if tail {
+				m.stack = m.stack[:f.base]
+				f.template, f.pc, f.env = callee.vm.template, 0, env
+			} else {
+				if err := m.interp.enter() ; err != nil {
+					return err
+				}
+				m.stack = m.stack[:at]
+				m.frames = append(m.frames, vmFrame{callee.vm.template, 0, env, at})
+			}
+			return nil
+		case callee.vm != nil && callee.vm.continuation != nil:
+			if n != 1 {
+				msg := fmt.Sprintf("Expected 1 arguments, got %d", n)
+				return evaluationError{"continuation", msg}
+			}
+			k, val := callee.vm.continuation, m.stack[at + 1]
+			if !m.canResume(k) {
+				return continuationInvoked{k, val}
+			}
+			m.resume(k, val)
+			return nil
+		case isCallCC(callee) && n == 1:
+			// The continuation is whatever would happen to
+			// the value of this call
+			receiver := m.stack[at + 1]
+			m.stack = m.stack[:at]
+			k := mkContinuation(m.capture())
+			m.stack = append(m.stack, receiver, k)
+			return m.call(1, lst, tail)
+		default:
+			args := append([]sexpr_general(nil), m.stack[at + 1:]...)
+			val, err := callee.apply(args, f.env)
+			if err != nil {
+				return err
+			}
+			m.stack = m.stack[:at]
+			m.push(val)
+			return nil
+		}
+	case macro_expr:
+		// A macro we couldn't see coming
+		val, err := callee.apply(lst, f.env)
+		if err != nil {
+			return err
+		}
+		m.stack = m.stack[:at]
+		m.push(val)
+		return nil
+	default:
+		msg := fmt.Sprintf("Attempt to apply non-procedure %q", callee)
+		return evaluationError{"(eval)", msg}
+	}
+}
@@ -0,0 +1,82 @@
+package sexpr
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestVMMatchesTreeWalk(t *testing.T) { checkParity(BackendVM, t) }
+func TestVMMatchesTreeWalkOnExamples(t *testing.T) { checkParityOnExamples(BackendVM, t) }
+
+func TestVM(t *testing.T) {
+	countdown := `
+(define countdown (lambda (n) (cond ((zero? n) 'done) (else (countdown (- n 1))))))
+(countdown 1000)
+`
+	tests := []struct{
+		input string
+		want string // a regexp matching the last result
+	} {
+		// Tail calls don't count toward the depth limit...
+		{ countdown, "^done$" },
+		// ...but other calls do
+		{ "(define f (lambda (n) (cond ((zero? n) 0) (else (+ 1 (f (- n 1))))))) (f 1000)",
+			"Exceeded the maximum call depth of 50" },
+		// and the bodies of receive and let-values are tail positions
+		{ "(define f (lambda (n) (receive (q r) (truncate/ n 2) (cond ((zero? n) 'done) (else (f (- n 1))))))) (f 1000)", "^done$" },
+		{ "(define f (lambda (n) (let-values (((a) (values n)) (all (values))) (cond ((zero? a) all) (else (f (- a 1))))))) (f 1000)", `^\(\)$` },
+		{ "(define f (lambda (n) (let*-values (((a) (values n)) ((b) (values a))) (cond ((zero? b) 'done) (else (f (- b 1))))))) (f 1000)", "^done$" },
+		{ "(+ 1 (call/cc (lambda (k) (+ 10 (k 5)))))", "^6$" },
+		{ "(call-with-current-continuation (lambda (k) 3))", "^3$" },
+		// Escaping through a primitive that called back into the VM
+		{ "(call/cc (lambda (k) (primitive? (k 'out))))", "^out$" },
+		// Re-entering a top-level form that has finished
+		{ "(define saved (call/cc (lambda (k) k))) (saved 5) saved", "^5$" },
+		{ "(define saved (call/cc (lambda (k) k))) (saved 5)", "^;; defined saved$" },
+		{ "(call/cc (lambda (k) (k 1 2)))", "Expected 1 arguments, got 2" },
+		{ "(call/cc car)", "is not a pair" },
+		{ "(disassemble (lambda (x) x))", `^\(\(0 local 0 0\) \(1 return\)\)$` },
+		{ "(disassemble car)", "car was not compiled to bytecode" },
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.SetBackend(BackendVM)
+		interp.SetLimits(Limits{MaxDepth: 50})
+		_, sexprs := Parse("test", mkRuneChannel(test.input))
+		var got sexpr_general
+		for sx := range sexprs {
+			got = interp.Evaluate(sx)
+		}
+		if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+			t.Errorf("Evaluate[%s] = %q, want %q", test.input, got.Sprint(), test.want)
+		}
+	}
+}
+
+func TestCallCCEscapesInEveryBackend(t *testing.T) {
+	tests := []struct{
+		input string
+		want string
+	} {
+		{ "(+ 1 (call/cc (lambda (k) (+ 10 (k 5)))))", "^6$" },
+		{ "(call/cc (lambda (k) (cons 1 (k 2))))", "^2$" },
+		{ "(define saved (call/cc (lambda (k) k))) (saved 5)",
+			"Continuation invoked outside of its extent" },
+	}
+	for _, backend := range []Backend{BackendTreeWalk, BackendClosure} {
+		for _, test := range tests {
+			interp := NewInterpreter()
+			interp.SetBackend(backend)
+			_, sexprs := Parse("test", mkRuneChannel(test.input))
+			var got sexpr_general
+			for sx := range sexprs {
+				got = interp.Evaluate(sx)
+			}
+			if ok, _ := regexp.MatchString(test.want, got.Sprint()) ; !ok {
+				t.Errorf("Backend %d: Evaluate[%s] = %q, want %q",
+					backend, test.input, got.Sprint(), test.want)
+			}
+		}
+	}
+}